@@ -2,9 +2,14 @@ package sc2client
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -16,15 +21,35 @@ type StepState struct {
 	Steps         uint32
 	ReceivedChats <-chan *sc2proto.ChatReceived
 	Stop          chan<- error
+	// StepCount is how many game loops the game advances after OnStep, 0 in realtime.
+	// Agents may change it in StepModeAgent for this and the following steps.
+	StepCount uint32
 }
 
+// StepMode sets how the game advances.
+type StepMode int
+
+const (
+	// StepModeRealtime plays the game in real time, the agent acting on the observations
+	// it gets along the way.
+	StepModeRealtime StepMode = iota
+	// StepModeFixed steps the game by a fixed number of game loops after every OnStep.
+	StepModeFixed
+	// StepModeAgent steps the game by the number of game loops the agent sets in
+	// StepState.StepCount.
+	StepModeAgent
+)
+
 type PlayerSetup struct {
 	Type       sc2proto.PlayerType
 	Race       sc2proto.Race
 	Name       string
 	Difficulty sc2proto.Difficulty
 	AIBuild    sc2proto.AIBuild
-	Agent      PlayerAgent
+	Interface  *InterfaceOptions
+	// ObservedPlayerId is the player an observer watches, 0 meaning everyone.
+	ObservedPlayerId uint32
+	Agent            PlayerAgent
 }
 
 type PlayerAgent interface {
@@ -40,12 +65,24 @@ type Client struct {
 	windowX      int
 	windowY      int
 	rpcTimeout   time.Duration
+	stepMode     StepMode
+	stepCount    uint32
+	replayDir    string
+	launcherOpts []func(*Launcher)
+	connOpts     []func(*Connection)
+	wrapConn     func(Transport) Transport
+	attachHost   string
+	attachPort   int
 	launcher     *Launcher
 	conn         *Connection
 	rpc          *RpcClient
+	exited       chan struct{}
 	deferList    []func()
 	stop         chan error
 	playerId     uint32
+	gameMap      string
+	seed         uint32
+	result       sc2proto.Result
 	agent        PlayerAgent
 }
 
@@ -64,12 +101,67 @@ func ClientWindowOpts(windowWidth int, windowHeight int, windowX int, windowY in
 	}
 }
 
+// ClientStepModeOpts sets how the game advances. stepCount is the number of game loops
+// of each step, or its default in StepModeAgent.
+func ClientStepModeOpts(mode StepMode, stepCount uint32) func(*Client) {
+	return func(client *Client) {
+		client.setStepMode(mode, stepCount)
+	}
+}
+
+func (c *Client) setStepMode(mode StepMode, stepCount uint32) {
+	if stepCount == 0 {
+		stepCount = 1
+	}
+	c.stepMode = mode
+	c.stepCount = stepCount
+}
+
+// ClientReplayDirOpts saves the replay of every game the Client hosts to dir, along with
+// its ReplayMeta. Games joined with JoinGameAs are not saved, their map and seed are only
+// known to the host.
+func ClientReplayDirOpts(dir string) func(*Client) {
+	return func(client *Client) {
+		client.replayDir = dir
+	}
+}
+
 func ClientRpcOpts(timeout time.Duration) func(*Client) {
 	return func(client *Client) {
 		client.rpcTimeout = timeout
 	}
 }
 
+// ClientLauncherOpts passes options to the Launcher of the game process.
+func ClientLauncherOpts(opts ...func(*Launcher)) func(*Client) {
+	return func(client *Client) {
+		client.launcherOpts = append(client.launcherOpts, opts...)
+	}
+}
+
+func ClientReconnectOpts(policy ReconnectPolicy) func(*Client) {
+	return func(client *Client) {
+		client.connOpts = append(client.connOpts, ConnReconnectOpts(policy))
+	}
+}
+
+// ClientTransportOpts wraps the connection to the game, e.g. in a RecordingTransport
+// to capture the whole session.
+func ClientTransportOpts(wrap func(Transport) Transport) func(*Client) {
+	return func(client *Client) {
+		client.wrapConn = wrap
+	}
+}
+
+// ClientAttachOpts connects the Client to a game already listening on host:port instead
+// of launching a new process, e.g. a game started by hand or an sc2test.Server.
+func ClientAttachOpts(host string, port int) func(*Client) {
+	return func(client *Client) {
+		client.attachHost = host
+		client.attachPort = port
+	}
+}
+
 func NewClient(opts ...func(*Client)) *Client {
 	client := &Client{
 		displayMode:  0,
@@ -87,33 +179,24 @@ func NewClient(opts ...func(*Client)) *Client {
 }
 
 func (c *Client) Init(ctx context.Context) error {
-	host, port, err := GetLocalAddress()
-	if err != nil {
-		return fmt.Errorf("GetLocalAddress() error: %w", err)
-	}
+	var err error
 	defer func() {
 		if err != nil {
 			c.Finalize()
 		}
 	}()
 
-	c.launcher, err = NewLauncher(host, port, c.displayMode, c.windowWidth, c.windowHeight, c.windowX, c.windowY)
-	if err != nil {
-		return fmt.Errorf("NewLauncher() error: %w", err)
-	}
-	err = c.launcher.StartProcess(ctx)
-	if err != nil {
-		return fmt.Errorf("StartProcess() error: %w", err)
+	host, port := c.attachHost, c.attachPort
+	if host == "" {
+		host, port, err = c.startProcess(ctx)
+		if err != nil {
+			return fmt.Errorf("c.startProcess() error: %w", err)
+		}
+	} else {
+		log.Println("attach to game on:", host+":"+strconv.Itoa(port))
 	}
-	c.deferList = append(c.deferList, func() {
-		_ = c.launcher.StopProcess()
-	})
-	log.Println("game started:")
-	log.Println("process pid:", c.launcher.ProcessPid())
-	log.Println("server listen on:", host+":"+strconv.Itoa(port))
-	time.Sleep(10 * time.Second)
 
-	c.conn, err = DialSC2(ctx, host, port)
+	c.conn, err = DialSC2(ctx, host, port, c.connOpts...)
 	if err != nil {
 		return fmt.Errorf("DialSC2() error: %w", err)
 	}
@@ -121,7 +204,11 @@ func (c *Client) Init(ctx context.Context) error {
 		c.conn.Close()
 	})
 
-	c.rpc = NewRpcClient(c.conn, c.rpcTimeout)
+	var transport Transport = c.conn
+	if c.wrapConn != nil {
+		transport = c.wrapConn(transport)
+	}
+	c.rpc = NewRpcClient(transport, c.rpcTimeout)
 	pingRsp, err := c.rpc.Ping(ctx)
 	if err != nil {
 		return fmt.Errorf("c.rpc.Ping() error: %w", err)
@@ -130,14 +217,121 @@ func (c *Client) Init(ctx context.Context) error {
 	log.Println("base build:", pingRsp.GetBaseBuild())
 	log.Println("data version", pingRsp.GetDataVersion())
 	log.Println("data build:", pingRsp.GetDataBuild())
+	var processExited <-chan struct{}
+	if c.launcher != nil {
+		processExited = c.launcher.Exited()
+	}
+	c.exited = make(chan struct{})
+	go func() {
+		select {
+		case <-c.rpc.Done():
+		case <-processExited:
+		}
+		close(c.exited)
+	}()
 	c.deferList = append(c.deferList, func() {
-		_, _ = c.rpc.Quit(context.Background(), &sc2proto.RequestQuit{})
-		time.Sleep(10 * time.Second)
+		if c.launcher == nil {
+			_ = c.quitGame()
+			return
+		}
+		err := c.launcher.Shutdown(c.quitGame)
+		if err != nil {
+			log.Println("[WARN] c.launcher.Shutdown() error:", err)
+		}
 	})
 
 	return nil
 }
 
+// quitGame asks the game to exit. The game closes the connection instead of answering,
+// so once the request is sent, losing the connection or getting no response counts as
+// success.
+func (c *Client) quitGame() error {
+	select {
+	case <-c.rpc.Done():
+		return nil
+	default:
+	}
+	id, err := c.rpc.SendRequest(context.Background(), newQuitRequest(&sc2proto.RequestQuit{}))
+	if err != nil {
+		return fmt.Errorf("c.rpc.SendRequest() error: %w", err)
+	}
+	_, err = c.rpc.WaitForResponse(context.Background(), id)
+	if err != nil && !errors.Is(err, ErrConnectionClosed) && !errors.Is(err, ErrConnectionReset) && !errors.Is(err, ErrTimeout) {
+		return fmt.Errorf("c.rpc.WaitForResponse() error: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) startProcess(ctx context.Context) (string, int, error) {
+	host, port, err := GetLocalAddress()
+	if err != nil {
+		return "", 0, fmt.Errorf("GetLocalAddress() error: %w", err)
+	}
+	c.launcher, err = NewLauncher(host, port, c.displayMode, c.windowWidth, c.windowHeight, c.windowX, c.windowY, c.launcherOpts...)
+	if err != nil {
+		return "", 0, fmt.Errorf("NewLauncher() error: %w", err)
+	}
+	err = c.launcher.StartProcess(ctx)
+	if err != nil {
+		return "", 0, fmt.Errorf("StartProcess() error: %w", err)
+	}
+	c.deferList = append(c.deferList, func() {
+		err := c.launcher.StopProcess()
+		if err != nil {
+			log.Println("[WARN] c.launcher.StopProcess() error:", err)
+		}
+	})
+	log.Println("game started:")
+	log.Println("process pid:", c.launcher.ProcessPid())
+	log.Println("server listen on:", host+":"+strconv.Itoa(port))
+	err = c.launcher.WaitReady(ctx)
+	if err != nil {
+		return "", 0, fmt.Errorf("c.launcher.WaitReady() error: %w", err)
+	}
+	return host, port, nil
+}
+
+// Exited returns a channel that is closed when the game instance is gone: its process
+// exited or the connection to it was lost.
+func (c *Client) Exited() <-chan struct{} {
+	return c.exited
+}
+
+// gone reports whether the game instance is gone. Unlike Exited, which is closed by a
+// separate goroutine, it checks the connection and the process directly, so it is
+// already true once a request failed on the lost connection.
+func (c *Client) gone() bool {
+	if c.rpc != nil {
+		select {
+		case <-c.rpc.Done():
+			return true
+		default:
+		}
+	}
+	if c.launcher != nil {
+		select {
+		case <-c.launcher.Exited():
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// Err returns why the game instance is gone, if it is.
+func (c *Client) Err() error {
+	if c.launcher != nil {
+		if err := c.launcher.Err(); err != nil {
+			return err
+		}
+	}
+	if c.rpc != nil {
+		return c.rpc.Err()
+	}
+	return nil
+}
+
 func (c *Client) Finalize() {
 	for i := len(c.deferList) - 1; i >= 0; i-- {
 		c.deferList[i]()
@@ -145,7 +339,30 @@ func (c *Client) Finalize() {
 	c.deferList = nil
 }
 
-func (c *Client) HostGame(ctx context.Context, portConfig *PortConfig, gameMap string, players []*PlayerSetup, disableFog bool) error {
+// CreateGameSeedOpts sets the random seed of a hosted game, so it can be reproduced.
+func CreateGameSeedOpts(seed uint32) func(*sc2proto.RequestCreateGame) {
+	return func(req *sc2proto.RequestCreateGame) {
+		req.RandomSeed = proto.Uint32(seed)
+	}
+}
+
+// HostGame creates a game and joins it as the first participant or observer of players,
+// the others joining with JoinGameAs. portConfig may be nil when no one else joins, e.g.
+// against computer players only, so the game is hosted without ports. Without
+// CreateGameSeedOpts the game is seeded with the current time, the seed is logged either
+// way and available from Seed.
+func (c *Client) HostGame(ctx context.Context, portConfig *PortConfig, gameMap string, players []*PlayerSetup, disableFog bool, opts ...func(*sc2proto.RequestCreateGame)) error {
+	err := validatePlayerSetups(players)
+	if err != nil {
+		return fmt.Errorf("validatePlayerSetups() error: %w", err)
+	}
+	instances := gameInstancePlayers(players)
+	if len(instances) == 0 {
+		return fmt.Errorf("no participant or observer to join the game")
+	}
+	if portConfig == nil && len(instances) > 1 {
+		return fmt.Errorf("need a port config for %d participants and observers", len(instances))
+	}
 	var playerSetups []*sc2proto.PlayerSetup
 	for _, player := range players {
 		playerSetups = append(playerSetups, &sc2proto.PlayerSetup{
@@ -156,7 +373,7 @@ func (c *Client) HostGame(ctx context.Context, portConfig *PortConfig, gameMap s
 			AiBuild:    player.AIBuild.Enum(),
 		})
 	}
-	createGameRsp, err := c.rpc.CreateGame(ctx, &sc2proto.RequestCreateGame{
+	createGameReq := &sc2proto.RequestCreateGame{
 		Map: &sc2proto.RequestCreateGame_LocalMap{
 			LocalMap: &sc2proto.LocalMap{
 				MapPath: proto.String(gameMap),
@@ -165,42 +382,72 @@ func (c *Client) HostGame(ctx context.Context, portConfig *PortConfig, gameMap s
 		PlayerSetup: playerSetups,
 		DisableFog:  proto.Bool(disableFog),
 		RandomSeed:  proto.Uint32(uint32(time.Now().Unix())),
-		Realtime:    proto.Bool(true),
-	})
+		Realtime:    proto.Bool(c.stepMode == StepModeRealtime),
+	}
+	for _, option := range opts {
+		option(createGameReq)
+	}
+	_, err = c.rpc.CreateGame(ctx, createGameReq)
 	if err != nil {
 		return fmt.Errorf("c.rpc.CreateGame() error: %w", err)
 	}
-	if createGameRsp.GetError() > sc2proto.ResponseCreateGame_MissingMap {
-		return fmt.Errorf("create game error: %s, %s",
-			createGameRsp.GetError().String(), createGameRsp.GetErrorDetails())
+	c.gameMap = gameMap
+	c.seed = createGameReq.GetRandomSeed()
+	log.Printf("game created, map: %s, seed: %d\n", c.gameMap, c.seed)
+
+	return c.joinGame(ctx, portConfig, players, instances[0])
+}
+
+// JoinGame joins the game created by the host as players[1], the opponent of a two
+// player game. Use JoinGameAs to join a game with more players.
+func (c *Client) JoinGame(ctx context.Context, portConfig *PortConfig, players []*PlayerSetup) error {
+	return c.JoinGameAs(ctx, portConfig, players, 1)
+}
+
+// JoinGameAs joins the game created by the host as players[index], which must be a
+// participant or an observer.
+func (c *Client) JoinGameAs(ctx context.Context, portConfig *PortConfig, players []*PlayerSetup, index int) error {
+	// the game and its seed are chosen by the host
+	c.gameMap = ""
+	c.seed = 0
+	err := validatePlayerSetups(players)
+	if err != nil {
+		return fmt.Errorf("validatePlayerSetups() error: %w", err)
+	}
+	if index < 0 || index >= len(players) || players[index].Type == sc2proto.PlayerType_Computer {
+		return fmt.Errorf("invalid player index: %d", index)
 	}
+	return c.joinGame(ctx, portConfig, players, index)
+}
 
-	joinGameRsp, err := c.rpc.JoinGame(ctx, &sc2proto.RequestJoinGame{
+func (c *Client) joinGame(ctx context.Context, portConfig *PortConfig, players []*PlayerSetup, index int) error {
+	player := players[index]
+	options, err := player.interfaceOptions()
+	if err != nil {
+		return fmt.Errorf("players[%d].interfaceOptions() error: %w", index, err)
+	}
+	joinGameReq := &sc2proto.RequestJoinGame{
 		Participation: &sc2proto.RequestJoinGame_Race{
-			Race: players[0].Race,
-		},
-		ServerPorts: &sc2proto.PortSet{
-			GamePort: proto.Int32(int32(portConfig.Servers[0])),
-			BasePort: proto.Int32(int32(portConfig.Servers[1])),
+			Race: player.Race,
 		},
-		ClientPorts: []*sc2proto.PortSet{
-			{
-				GamePort: proto.Int32(int32(portConfig.Players[0])),
-				BasePort: proto.Int32(int32(portConfig.Players[1])),
-			},
-		},
-		PlayerName: proto.String(players[0].Name),
-	})
+		PlayerName: proto.String(player.Name),
+		Options:    options,
+	}
+	if player.Type == sc2proto.PlayerType_Observer {
+		joinGameReq.Participation = &sc2proto.RequestJoinGame_ObservedPlayerId{
+			ObservedPlayerId: player.ObservedPlayerId,
+		}
+	}
+	if portConfig != nil {
+		joinGameReq.ServerPorts, joinGameReq.ClientPorts = portConfig.portSets()
+	}
+	joinGameRsp, err := c.rpc.JoinGame(ctx, joinGameReq)
 	if err != nil {
 		return fmt.Errorf("c.rpc.JoinGame() error: %w", err)
 	}
-	if joinGameRsp.GetError() > sc2proto.ResponseJoinGame_MissingParticipation {
-		return fmt.Errorf("participant join game error: %s, %s",
-			joinGameRsp.GetError().String(), joinGameRsp.GetErrorDetails())
-	}
 
 	c.playerId = joinGameRsp.GetPlayerId()
-	c.agent = players[0].Agent
+	c.agent = player.Agent
 
 	if c.agent != nil {
 		c.agent.OnStart(c.playerId, c.rpc)
@@ -209,66 +456,158 @@ func (c *Client) HostGame(ctx context.Context, portConfig *PortConfig, gameMap s
 	return nil
 }
 
-func (c *Client) JoinGame(ctx context.Context, portConfig *PortConfig, players []*PlayerSetup) error {
-	joinGameRsp, err := c.rpc.JoinGame(ctx, &sc2proto.RequestJoinGame{
-		Participation: &sc2proto.RequestJoinGame_Race{
-			Race: players[1].Race,
-		},
-		ServerPorts: &sc2proto.PortSet{
-			GamePort: proto.Int32(int32(portConfig.Servers[0])),
-			BasePort: proto.Int32(int32(portConfig.Servers[1])),
-		},
-		ClientPorts: []*sc2proto.PortSet{
-			{
-				GamePort: proto.Int32(int32(portConfig.Players[0])),
-				BasePort: proto.Int32(int32(portConfig.Players[1])),
-			},
-		},
-		PlayerName: proto.String(players[1].Name),
-	})
+// validatePlayerSetups checks the players against the requests HostGame and JoinGameAs
+// build from them: only the players joining through a game instance have an interface,
+// observers need one to observe anything, and only observers watch a player.
+func validatePlayerSetups(players []*PlayerSetup) error {
+	for i, player := range players {
+		if player.Type == sc2proto.PlayerType_Observer && player.Interface == nil {
+			return fmt.Errorf("player %d: observers need interface options", i+1)
+		}
+		if player.ObservedPlayerId != 0 {
+			if player.Type != sc2proto.PlayerType_Observer {
+				return fmt.Errorf("player %d: only observers watch a player", i+1)
+			}
+			if int(player.ObservedPlayerId) > len(players) {
+				return fmt.Errorf("player %d: observed player %d not in game", i+1, player.ObservedPlayerId)
+			}
+		}
+		if player.Interface == nil {
+			continue
+		}
+		if player.Type == sc2proto.PlayerType_Computer {
+			return fmt.Errorf("player %d: computer players have no interface", i+1)
+		}
+		err := player.Interface.validate()
+		if err != nil {
+			return fmt.Errorf("player %d: invalid interface options: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// gameInstancePlayers returns the indexes of the players that join through a game
+// instance, i.e. all but the built-in computer players.
+func gameInstancePlayers(players []*PlayerSetup) []int {
+	var indexes []int
+	for i, player := range players {
+		if player.Type != sc2proto.PlayerType_Computer {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// leaveGame makes the instance leave the game it may still be in.
+func (c *Client) leaveGame(ctx context.Context) error {
+	// responses to abandoned requests arrive before the ping's, so the status is up to date
+	_, err := c.rpc.Ping(ctx)
 	if err != nil {
-		return fmt.Errorf("c.rpc.JoinGame() error: %w", err)
+		return fmt.Errorf("c.rpc.Ping() error: %w", err)
 	}
-	if joinGameRsp.GetError() > sc2proto.ResponseJoinGame_MissingParticipation {
-		return fmt.Errorf("participant join game error: %s, %s",
-			joinGameRsp.GetError().String(), joinGameRsp.GetErrorDetails())
+	status := c.rpc.Status()
+	if status != sc2proto.Status_in_game && status != sc2proto.Status_ended {
+		return nil
 	}
+	_, err = c.rpc.LeaveGame(ctx, &sc2proto.RequestLeaveGame{})
+	if err != nil {
+		return fmt.Errorf("c.rpc.LeaveGame() error: %w", err)
+	}
+	return nil
+}
 
-	c.playerId = joinGameRsp.GetPlayerId()
-	c.agent = players[1].Agent
+// stopGrace is how long requests in progress are given to finish after their context is
+// done, see gracefulContext.
+const stopGrace = time.Second
 
-	if c.agent != nil {
-		c.agent.OnStart(c.playerId, c.rpc)
-	}
+// detachedContext keeps the values of its parent but not its cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
 
+func (detachedContext) Done() <-chan struct{} {
 	return nil
 }
 
+func (detachedContext) Err() error {
+	return nil
+}
+
+// gracefulContext returns a context with the values of ctx that is cancelled stopGrace
+// after ctx is done, or once cancel is called. A write cancelled half way closes the
+// connection, so a loop stopping on ctx between two requests makes them with the returned
+// context, which only cancels a request still in progress after the grace period.
+func gracefulContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	reqCtx, cancel := context.WithCancel(detachedContext{ctx})
+	go func() {
+		select {
+		case <-ctx.Done():
+			timer := time.NewTimer(stopGrace)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-reqCtx.Done():
+			}
+		case <-reqCtx.Done():
+		}
+		cancel()
+	}()
+	return reqCtx, cancel
+}
+
+// StartGameLoop plays the game until it ends or ctx is done, which is checked between two
+// steps, see gracefulContext.
 func (c *Client) StartGameLoop(ctx context.Context) {
+	c.result = 0
+	reqCtx, cancel := gracefulContext(ctx)
 	go func() {
+		defer cancel()
 		stopStep := make(chan error, 1)
 		var prevStep uint32
+		var stepCount uint32
 		receivedChats := make(chan *sc2proto.ChatReceived, 100)
 	gameLoop:
 		for {
-			resp, err := c.rpc.Observation(ctx, &sc2proto.RequestObservation{})
+			var resp *sc2proto.ResponseObservation
+			var err error
+			if stepCount == 0 {
+				resp, err = c.rpc.Observation(reqCtx, &sc2proto.RequestObservation{})
+				if err != nil {
+					err = fmt.Errorf("c.rpc.Observation() error: %w", err)
+				}
+			} else {
+				_, resp, err = c.rpc.ActAndObserve(reqCtx, nil, &sc2proto.RequestStep{
+					Count: proto.Uint32(stepCount),
+				}, &sc2proto.RequestObservation{})
+				if err != nil {
+					err = fmt.Errorf("c.rpc.ActAndObserve() error: %w", err)
+				}
+			}
 			if err != nil {
-				c.stop <- fmt.Errorf("c.rpc.Observation() error: %w", err)
+				c.stop <- err
 				break gameLoop
 			}
 			playerResult := resp.GetPlayerResult()
 			if len(playerResult) > 0 {
-				if c.agent != nil {
-					for _, result := range playerResult {
-						if result.GetPlayerId() == c.playerId {
+				for _, result := range playerResult {
+					if result.GetPlayerId() == c.playerId {
+						c.result = result.GetResult()
+						if c.agent != nil {
 							c.agent.OnEnd(result.GetResult())
-							break
 						}
+						break
 					}
 				}
 				c.stop <- nil
 				break gameLoop
 			}
+			if c.stepMode != StepModeRealtime && stepCount == 0 {
+				stepCount = c.stepCount
+			}
 			if c.agent != nil {
 				for _, chat := range resp.GetChat() {
 					select {
@@ -278,13 +617,19 @@ func (c *Client) StartGameLoop(ctx context.Context) {
 					}
 				}
 
-				if resp.GetObservation().GetGameLoop() > prevStep {
-					c.agent.OnStep(ctx, &StepState{
+				// in step mode the game waits for the agent, which steps on every observation
+				if resp.GetObservation().GetGameLoop() > prevStep || c.stepMode != StepModeRealtime {
+					state := &StepState{
 						Steps:         resp.GetObservation().GetGameLoop(),
 						ReceivedChats: receivedChats,
 						Stop:          stopStep,
-					})
+						StepCount:     stepCount,
+					}
+					c.agent.OnStep(reqCtx, state)
 					prevStep = resp.GetObservation().GetGameLoop()
+					if c.stepMode == StepModeAgent && state.StepCount > 0 {
+						stepCount = state.StepCount
+					}
 					select {
 					case err := <-stopStep:
 						if err != nil {
@@ -316,5 +661,68 @@ func (c *Client) WaitGameEnd() error {
 	if err != nil {
 		return fmt.Errorf("game loop end with error: %w", err)
 	}
+	if c.gameMap != "" {
+		log.Printf("game ended, map: %s, seed: %d, result: %s\n", c.gameMap, c.seed, c.result)
+	} else {
+		log.Println("game ended, result:", c.result)
+	}
+	if c.replayDir != "" && c.gameMap != "" {
+		err = c.saveReplay(context.Background())
+		if err != nil {
+			return fmt.Errorf("c.saveReplay() error: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReplayMeta is stored as JSON next to every replay a Client saves, so the game can be
+// re-run with the same map and seed.
+type ReplayMeta struct {
+	Map      string `json:"map"`
+	Seed     uint32 `json:"seed"`
+	PlayerID uint32 `json:"player_id"`
+	Result   string `json:"result"`
+}
+
+func (c *Client) saveReplay(ctx context.Context) error {
+	if c.gameMap == "" {
+		return fmt.Errorf("game not hosted by this client")
+	}
+	rsp, err := c.rpc.SaveReplay(ctx, &sc2proto.RequestSaveReplay{})
+	if err != nil {
+		return fmt.Errorf("c.rpc.SaveReplay() error: %w", err)
+	}
+	name := fmt.Sprintf("%s_%s_%d_%d", strings.TrimSuffix(filepath.Base(c.gameMap), ".SC2Map"),
+		time.Now().Format("20060102-150405"), c.seed, c.playerId)
+	replayPath := filepath.Join(c.replayDir, name+".SC2Replay")
+	err = os.WriteFile(replayPath, rsp.GetData(), 0o644)
+	if err != nil {
+		return fmt.Errorf("os.WriteFile(%s) error: %w", replayPath, err)
+	}
+	meta, err := json.MarshalIndent(&ReplayMeta{
+		Map:      c.gameMap,
+		Seed:     c.seed,
+		PlayerID: c.playerId,
+		Result:   c.result.String(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent() error: %w", err)
+	}
+	metaPath := filepath.Join(c.replayDir, name+".json")
+	err = os.WriteFile(metaPath, meta, 0o644)
+	if err != nil {
+		return fmt.Errorf("os.WriteFile(%s) error: %w", metaPath, err)
+	}
+	log.Println("replay saved:", replayPath)
 	return nil
 }
+
+// Seed returns the random seed of the last game hosted by the Client.
+func (c *Client) Seed() uint32 {
+	return c.seed
+}
+
+// Result returns the result of the last game played by the Client.
+func (c *Client) Result() sc2proto.Result {
+	return c.result
+}
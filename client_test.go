@@ -0,0 +1,285 @@
+package sc2client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
+)
+
+type recordAgent struct {
+	mutex    sync.Mutex
+	playerId uint32
+	steps    []uint32
+	result   sc2proto.Result
+	ended    bool
+	onEnd    func()
+}
+
+func (m *recordAgent) OnStart(playerId uint32, rpc *RpcClient) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.playerId = playerId
+}
+
+func (m *recordAgent) OnStep(ctx context.Context, state *StepState) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.steps = append(m.steps, state.Steps)
+}
+
+func (m *recordAgent) OnEnd(result sc2proto.Result) {
+	m.mutex.Lock()
+	m.result = result
+	m.ended = true
+	m.mutex.Unlock()
+	if m.onEnd != nil {
+		m.onEnd()
+	}
+}
+
+func TestClient_HostGame(t *testing.T) {
+	server := sc2test.NewServer(sc2test.ServerGameLengthOpts(64))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(ClientAttachOpts(server.Host(), server.Port()))
+	err := client.Init(ctx)
+	if err != nil {
+		t.Errorf("client.Init() error: %s", err)
+		return
+	}
+	defer client.Finalize()
+
+	agent := new(recordAgent)
+	err = client.HostGame(ctx, &PortConfig{}, "Test.SC2Map", []*PlayerSetup{
+		{
+			Type:  sc2proto.PlayerType_Participant,
+			Race:  sc2proto.Race_Terran,
+			Name:  "Agent",
+			Agent: agent,
+		},
+		{
+			Type:       sc2proto.PlayerType_Computer,
+			Race:       sc2proto.Race_Zerg,
+			Difficulty: sc2proto.Difficulty_Easy,
+		},
+	}, false)
+	if err != nil {
+		t.Errorf("client.HostGame() error: %s", err)
+		return
+	}
+	client.StartGameLoop(ctx)
+	err = client.WaitGameEnd()
+	if err != nil {
+		t.Errorf("client.WaitGameEnd() error: %s", err)
+		return
+	}
+
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	if agent.playerId != 1 || !agent.ended || agent.result != sc2proto.Result_Victory {
+		t.Errorf("unexpected agent state: player %d, ended %t, result %s", agent.playerId, agent.ended, agent.result)
+	}
+	if len(agent.steps) == 0 {
+		t.Errorf("agent.OnStep() never called")
+	}
+	if server.Status() != sc2proto.Status_launched {
+		t.Errorf("game not left, status: %s", server.Status())
+	}
+}
+
+// doublingAgent doubles the step count on every step.
+type doublingAgent struct {
+	recordAgent
+}
+
+func (m *doublingAgent) OnStep(ctx context.Context, state *StepState) {
+	m.recordAgent.OnStep(ctx, state)
+	state.StepCount *= 2
+}
+
+func TestClient_StepMode(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  StepMode
+		steps []uint32
+	}{
+		{name: "fixed", mode: StepModeFixed, steps: []uint32{0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 48, 52, 56, 60}},
+		{name: "agent", mode: StepModeAgent, steps: []uint32{0, 8, 24, 56}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := sc2test.NewServer(sc2test.ServerGameLengthOpts(64))
+			defer server.Close()
+
+			ctx := context.Background()
+			client := NewClient(ClientAttachOpts(server.Host(), server.Port()), ClientStepModeOpts(tt.mode, 4))
+			err := client.Init(ctx)
+			if err != nil {
+				t.Errorf("client.Init() error: %s", err)
+				return
+			}
+			defer client.Finalize()
+
+			agent := new(doublingAgent)
+			err = client.HostGame(ctx, &PortConfig{}, "Test.SC2Map", []*PlayerSetup{
+				{
+					Type:  sc2proto.PlayerType_Participant,
+					Race:  sc2proto.Race_Terran,
+					Agent: agent,
+				},
+				{
+					Type: sc2proto.PlayerType_Computer,
+					Race: sc2proto.Race_Zerg,
+				},
+			}, false)
+			if err != nil {
+				t.Errorf("client.HostGame() error: %s", err)
+				return
+			}
+			if server.CreateGame().GetRealtime() {
+				t.Errorf("game created in realtime")
+			}
+			client.StartGameLoop(ctx)
+			err = client.WaitGameEnd()
+			if err != nil {
+				t.Errorf("client.WaitGameEnd() error: %s", err)
+				return
+			}
+			agent.mutex.Lock()
+			defer agent.mutex.Unlock()
+			if !agent.ended || fmt.Sprint(agent.steps) != fmt.Sprint(tt.steps) {
+				t.Errorf("unexpected steps: ended %t, steps %v", agent.ended, agent.steps)
+			}
+		})
+	}
+}
+
+func TestClient_QuitGame(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+
+	client := NewClient(ClientAttachOpts(server.Host(), server.Port()))
+	err := client.Init(context.Background())
+	if err != nil {
+		t.Errorf("client.Init() error: %s", err)
+		return
+	}
+	defer client.Finalize()
+
+	// the server closes the connection instead of answering, as the game does
+	err = client.quitGame()
+	if err != nil {
+		t.Errorf("client.quitGame() error: %s", err)
+		return
+	}
+	if server.Status() != sc2proto.Status_quit {
+		t.Errorf("server not quit, status: %s", server.Status())
+	}
+}
+
+func TestClient_JoinGameValidate(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(ClientAttachOpts(server.Host(), server.Port()))
+	err := client.Init(ctx)
+	if err != nil {
+		t.Errorf("client.Init() error: %s", err)
+		return
+	}
+	defer client.Finalize()
+
+	players := []*PlayerSetup{
+		{
+			Type: sc2proto.PlayerType_Participant,
+			Race: sc2proto.Race_Terran,
+		},
+		{
+			Type:       sc2proto.PlayerType_Computer,
+			Race:       sc2proto.Race_Zerg,
+			Difficulty: sc2proto.Difficulty_Easy,
+		},
+		{
+			Type:             sc2proto.PlayerType_Observer,
+			ObservedPlayerId: 1,
+		},
+	}
+	tests := []struct {
+		name  string
+		index int
+		setup func()
+	}{
+		{name: "observer without interface", index: 2},
+		{name: "computer player", index: 1, setup: func() {
+			players[2].Interface = NewInterfaceOptions().Raw()
+		}},
+		{name: "computer interface", index: 2, setup: func() {
+			players[1].Interface = NewInterfaceOptions().Raw()
+		}},
+	}
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+		err = client.JoinGameAs(ctx, &PortConfig{}, players, tt.index)
+		if err == nil {
+			t.Errorf("%s: expected JoinGameAs() to fail", tt.name)
+		}
+	}
+	if len(server.Requests()) != 1 {
+		t.Errorf("invalid join requests sent: %v", server.Requests())
+	}
+}
+
+func TestClient_JoinGameReplay(t *testing.T) {
+	server := sc2test.NewServer(sc2test.ServerGameLengthOpts(64))
+	defer server.Close()
+
+	ctx := context.Background()
+	replayDir := t.TempDir()
+	client := NewClient(ClientAttachOpts(server.Host(), server.Port()), ClientReplayDirOpts(replayDir))
+	err := client.Init(ctx)
+	if err != nil {
+		t.Errorf("client.Init() error: %s", err)
+		return
+	}
+	defer client.Finalize()
+
+	err = client.JoinGame(ctx, &PortConfig{}, []*PlayerSetup{
+		{
+			Type: sc2proto.PlayerType_Participant,
+			Race: sc2proto.Race_Terran,
+		},
+		{
+			Type: sc2proto.PlayerType_Participant,
+			Race: sc2proto.Race_Zerg,
+		},
+	})
+	if err != nil {
+		t.Errorf("client.JoinGame() error: %s", err)
+		return
+	}
+	client.StartGameLoop(ctx)
+	err = client.WaitGameEnd()
+	if err != nil {
+		t.Errorf("client.WaitGameEnd() error: %s", err)
+		return
+	}
+	// a guest doesn't know the map nor the seed of the game
+	entries, err := os.ReadDir(replayDir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("unexpected replays of a joined game: %v, %v", entries, err)
+	}
+	for _, req := range server.Requests() {
+		if req.GetSaveReplay() != nil {
+			t.Errorf("replay requested by a guest")
+		}
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
+	"sync"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 	"nhooyr.io/websocket"
@@ -11,29 +14,200 @@ import (
 	"github.com/JinWuZhao/sc2client/sc2proto"
 )
 
+// ReconnectPolicy controls how a Connection redials the game after the websocket dropped.
+// Zero values are replaced by defaults. OnReconnect, if set, is called once a reconnection
+// finishes with the number of attempts made and nil, or the last error if it gave up.
+type ReconnectPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	OnReconnect    func(attempts int, err error)
+}
+
+var _ Transport = (*Connection)(nil)
+
 type Connection struct {
-	conn *websocket.Conn
+	host      string
+	port      int
+	conn      *websocket.Conn
+	mutex     sync.RWMutex
+	reconnect *ReconnectPolicy
+	timeout   time.Duration
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ConnReconnectOpts makes the Connection redial and re-ping the game when the websocket
+// drops. Reads in progress then fail with ErrConnectionReset, after which the
+// Connection can be used again.
+func ConnReconnectOpts(policy ReconnectPolicy) func(*Connection) {
+	return func(conn *Connection) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = 5
+		}
+		if policy.InitialBackoff <= 0 {
+			policy.InitialBackoff = 500 * time.Millisecond
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = 10 * time.Second
+		}
+		conn.reconnect = &policy
+	}
 }
 
-func DialSC2(ctx context.Context, host string, port int) (*Connection, error) {
+// ConnWriteTimeoutOpts sets how long a write may take before it gives up, 10 seconds by
+// default. The deadline of the context passed to Write still applies if it is earlier.
+func ConnWriteTimeoutOpts(timeout time.Duration) func(*Connection) {
+	return func(conn *Connection) {
+		conn.timeout = timeout
+	}
+}
+
+func dialWebsocket(ctx context.Context, host string, port int) (*websocket.Conn, error) {
 	wsURL := fmt.Sprintf("ws://%s:%d/sc2api", host, port)
 	conn, _, err := websocket.Dial(ctx, wsURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("websocket.Dial() error: %w", err)
 	}
-	return &Connection{
-		conn: conn,
-	}, nil
+	return conn, nil
+}
+
+func DialSC2(ctx context.Context, host string, port int, opts ...func(*Connection)) (*Connection, error) {
+	conn, err := dialWebsocket(ctx, host, port)
+	if err != nil {
+		return nil, fmt.Errorf("dialWebsocket() error: %w", err)
+	}
+	c := &Connection{
+		host:    host,
+		port:    port,
+		conn:    conn,
+		timeout: 10 * time.Second,
+		closed:  make(chan struct{}),
+	}
+	for _, option := range opts {
+		option(c)
+	}
+	return c, nil
+}
+
+func (c *Connection) current() *websocket.Conn {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn
+}
+
+func (c *Connection) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
 }
 
 func (c *Connection) Read(ctx context.Context, rsp *sc2proto.Response) error {
-	typ, r, err := c.conn.Reader(ctx)
+	err := readResponse(ctx, c.current(), rsp)
+	if err == nil {
+		return nil
+	}
+	if c.reconnect == nil || ctx.Err() != nil || c.isClosed() {
+		return err
+	}
+	log.Println("[WARN] connection lost, reconnecting. reason:", err)
+	attempts, rerr := c.redial(ctx)
+	if c.reconnect.OnReconnect != nil {
+		c.reconnect.OnReconnect(attempts, rerr)
+	}
+	if rerr != nil {
+		return fmt.Errorf("%w, reconnect failed after %d attempts: %v", err, attempts, rerr)
+	}
+	return fmt.Errorf("%w: %v", ErrConnectionReset, err)
+}
+
+func (c *Connection) Write(ctx context.Context, req *sc2proto.Request) error {
+	// the websocket is closed when a write gets cancelled half way, so nothing is written
+	// for a caller that already gave up
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := writeRequest(ctx, c.current(), req)
+	if err != nil && c.reconnect != nil && !c.isClosed() {
+		return fmt.Errorf("%w: %v", ErrConnectionReset, err)
+	}
+	return err
+}
+
+func (c *Connection) redial(ctx context.Context) (int, error) {
+	backoff := c.reconnect.InitialBackoff
+	var err error
+	attempt := 1
+	for ; attempt <= c.reconnect.MaxAttempts; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-c.closed:
+			timer.Stop()
+			return attempt, ErrConnectionClosed
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+		var conn *websocket.Conn
+		conn, err = c.dialAndPing(ctx)
+		if err == nil {
+			c.mutex.Lock()
+			if c.isClosed() {
+				c.mutex.Unlock()
+				_ = conn.Close(websocket.StatusNormalClosure, "")
+				return attempt, ErrConnectionClosed
+			}
+			prevConn := c.conn
+			c.conn = conn
+			c.mutex.Unlock()
+			_ = prevConn.Close(websocket.StatusGoingAway, "")
+			return attempt, nil
+		}
+		log.Printf("[WARN] reconnect attempt %d failed: %s\n", attempt, err)
+		backoff *= 2
+		if backoff > c.reconnect.MaxBackoff {
+			backoff = c.reconnect.MaxBackoff
+		}
+	}
+	return attempt - 1, err
+}
+
+func (c *Connection) dialAndPing(ctx context.Context) (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, err := dialWebsocket(ctx, c.host, c.port)
+	if err != nil {
+		return nil, fmt.Errorf("dialWebsocket() error: %w", err)
+	}
+	err = writeRequest(ctx, conn, newPingRequest())
+	if err == nil {
+		rsp := &sc2proto.Response{}
+		err = readResponse(ctx, conn, rsp)
+		if err == nil && rsp.GetPing() == nil {
+			err = fmt.Errorf("unexpected ping response: %v", rsp)
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("c.conn.Reader() error: %w", err)
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+		return nil, err
+	}
+	return conn, nil
+}
+
+func readResponse(ctx context.Context, conn *websocket.Conn, rsp *sc2proto.Response) error {
+	typ, r, err := conn.Reader(ctx)
+	if err != nil {
+		return fmt.Errorf("conn.Reader() error: %w", err)
 	}
 
 	if typ != websocket.MessageBinary {
-		_ = c.conn.Close(websocket.StatusUnsupportedData, "expected binary message")
+		_ = conn.Close(websocket.StatusUnsupportedData, "expected binary message")
 		return fmt.Errorf("expected binary message for protobuf but got: %v", typ)
 	}
 
@@ -44,27 +218,33 @@ func (c *Connection) Read(ctx context.Context, rsp *sc2proto.Response) error {
 
 	err = proto.Unmarshal(b, rsp)
 	if err != nil {
-		_ = c.conn.Close(websocket.StatusInvalidFramePayloadData, "failed to unmarshal protobuf")
+		_ = conn.Close(websocket.StatusInvalidFramePayloadData, "failed to unmarshal protobuf")
 		return fmt.Errorf("failed to unmarshal protobuf: %w", err)
 	}
 
 	return nil
 }
 
-func (c *Connection) Write(ctx context.Context, req *sc2proto.Request) error {
+func writeRequest(ctx context.Context, conn *websocket.Conn, req *sc2proto.Request) error {
 	b, err := proto.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("proto.Marshal() error: %w", err)
 	}
 
-	err = c.conn.Write(ctx, websocket.MessageBinary, b)
+	err = conn.Write(ctx, websocket.MessageBinary, b)
 	if err != nil {
-		return fmt.Errorf("c.conn.Write() error: %w", err)
+		return fmt.Errorf("conn.Write() error: %w", err)
 	}
 
 	return nil
 }
 
 func (c *Connection) Close() {
-	_ = c.conn.Close(websocket.StatusNoStatusRcvd, "")
+	c.closeOnce.Do(func() {
+		c.mutex.Lock()
+		close(c.closed)
+		conn := c.conn
+		c.mutex.Unlock()
+		_ = conn.Close(websocket.StatusNoStatusRcvd, "")
+	})
 }
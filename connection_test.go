@@ -2,10 +2,19 @@ package sc2client
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"nhooyr.io/websocket"
+
 	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
 )
 
 func TestConnection_WriteRead(t *testing.T) {
@@ -54,3 +63,119 @@ func TestConnection_WriteRead(t *testing.T) {
 		return
 	}
 }
+
+func TestConnection_Reconnect(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	server.DropOn("ping")
+	conn := dialServer(t, server)
+	reconnected := make(chan error, 1)
+	ConnReconnectOpts(ReconnectPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		OnReconnect: func(attempts int, err error) {
+			reconnected <- err
+		},
+	})(conn)
+	rpcCli := NewRpcClient(conn, 5*time.Second)
+
+	_, err := rpcCli.Ping(context.Background())
+	if !errors.Is(err, ErrConnectionReset) {
+		t.Errorf("expected ErrConnectionReset but got: %v", err)
+		return
+	}
+	select {
+	case err := <-reconnected:
+		if err != nil {
+			t.Errorf("reconnect error: %s", err)
+			return
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("reconnect hook not called")
+		return
+	}
+	_, err = rpcCli.Ping(context.Background())
+	if err != nil {
+		t.Errorf("rpcCli.Ping() error after reconnect: %s", err)
+		return
+	}
+	if rpcCli.Err() != nil {
+		t.Errorf("unexpected rpcCli.Err(): %s", rpcCli.Err())
+		return
+	}
+}
+
+func TestConnection_WriteTimeout(t *testing.T) {
+	// the server never reads, so big writes block once the socket buffers are full
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		<-stop
+	}))
+	defer server.Close()
+	defer close(stop)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	req := &sc2proto.Request{
+		Request: &sc2proto.Request_SaveMap{
+			SaveMap: &sc2proto.RequestSaveMap{
+				MapData: []byte(strings.Repeat("x", 64<<20)),
+			},
+		},
+	}
+	write := func(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+		conn, err := DialSC2(context.Background(), host, portNum, ConnWriteTimeoutOpts(timeout))
+		if err != nil {
+			t.Fatalf("DialSC2() error: %s", err)
+		}
+		defer conn.Close()
+		start := time.Now()
+		err = conn.Write(ctx, req)
+		return time.Since(start), err
+	}
+
+	// without a deadline the write gives up after the write timeout
+	elapsed, err := write(context.Background(), 100*time.Millisecond)
+	if err == nil || elapsed > 5*time.Second {
+		t.Errorf("unexpected write without deadline: %s, %v", elapsed, err)
+	}
+
+	// the deadline of the caller is kept
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	elapsed, err = write(ctx, time.Hour)
+	if !errors.Is(err, context.DeadlineExceeded) || elapsed > 5*time.Second {
+		t.Errorf("unexpected write with deadline: %s, %v", elapsed, err)
+	}
+
+	// so is its cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	elapsed, err = write(ctx, time.Hour)
+	if !errors.Is(err, context.Canceled) || elapsed > time.Second {
+		t.Errorf("unexpected cancelled write: %s, %v", elapsed, err)
+	}
+
+	// nothing is written once the caller gave up, leaving the connection usable
+	pingServer := sc2test.NewServer()
+	defer pingServer.Close()
+	conn := dialServer(t, pingServer)
+	ping := &sc2proto.Request{
+		Request: &sc2proto.Request_Ping{
+			Ping: &sc2proto.RequestPing{},
+		},
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = conn.Write(ctx, ping)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got: %v", err)
+	}
+	err = conn.Write(context.Background(), ping)
+	if err != nil {
+		t.Errorf("conn.Write() error: %s", err)
+	}
+}
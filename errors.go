@@ -0,0 +1,123 @@
+package sc2client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+var (
+	ErrTimeout          = errors.New("sc2client: timeout")
+	ErrConnectionClosed = errors.New("sc2client: connection closed")
+	ErrGameEnded        = errors.New("sc2client: game ended")
+	ErrConnectionReset  = errors.New("sc2client: connection reset")
+	ErrPoolClosed       = errors.New("sc2client: pool closed")
+)
+
+// ConnectionClosedError is returned once the connection to the game is lost. It matches
+// ErrConnectionClosed and unwraps to the error that closed the connection, e.g. a
+// websocket close status or a net error.
+type ConnectionClosedError struct {
+	Err error
+}
+
+func (e *ConnectionClosedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConnectionClosed, e.Err)
+}
+
+func (e *ConnectionClosedError) Is(target error) bool {
+	return target == ErrConnectionClosed
+}
+
+func (e *ConnectionClosedError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseError is returned when the game answers a request with an error. Errors holds
+// the raw strings of Response.error, while Code holds the typed error enum of the response
+// payload (e.g. sc2proto.ResponseCreateGame_Error or sc2proto.ResponseJoinGame_Error).
+type ResponseError struct {
+	Request string
+	Status  sc2proto.Status
+	Errors  []string
+	Code    protoreflect.Enum
+	Details string
+}
+
+func (e *ResponseError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "sc2 client response error: request: %s, status: %s", e.Request, e.Status)
+	if len(e.Errors) > 0 {
+		fmt.Fprintf(&sb, ", errors: %s", strings.Join(e.Errors, "; "))
+	}
+	if e.Code != nil {
+		fmt.Fprintf(&sb, ", code: %v", e.Code)
+	}
+	if e.Details != "" {
+		fmt.Fprintf(&sb, ", details: %s", e.Details)
+	}
+	return sb.String()
+}
+
+func (e *ResponseError) Is(target error) bool {
+	return target == ErrGameEnded && e.Status == sc2proto.Status_ended
+}
+
+// StatusError is returned without a round trip when a request is not valid in the
+// status the game last reported.
+type StatusError struct {
+	Request string
+	Status  sc2proto.Status
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request %s is not valid in status %s", e.Request, e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrGameEnded && e.Status == sc2proto.Status_ended
+}
+
+// BuildNotFoundError is returned when the requested base build isn't installed.
+type BuildNotFoundError struct {
+	BaseBuild  uint32
+	InstallDir string
+	Installed  []uint32
+}
+
+func (e *BuildNotFoundError) Error() string {
+	return fmt.Sprintf("base build %d not found in %s, installed builds: %v", e.BaseBuild, e.InstallDir, e.Installed)
+}
+
+// ProcessExitError is returned when the game process exits without being asked to. The
+// temp dir of the process is kept for inspection.
+type ProcessExitError struct {
+	Pid        int
+	ExitCode   int
+	Err        error
+	LastLines  []string
+	CrashDumps []string
+}
+
+func (e *ProcessExitError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "game process %d exited unexpectedly, exit code: %d", e.Pid, e.ExitCode)
+	if e.Err != nil {
+		fmt.Fprintf(&sb, ", error: %s", e.Err)
+	}
+	if len(e.CrashDumps) > 0 {
+		fmt.Fprintf(&sb, ", crash dumps: %s", strings.Join(e.CrashDumps, ", "))
+	}
+	if len(e.LastLines) > 0 {
+		fmt.Fprintf(&sb, ", last output:\n%s", strings.Join(e.LastLines, "\n"))
+	}
+	return sb.String()
+}
+
+func (e *ProcessExitError) Unwrap() error {
+	return e.Err
+}
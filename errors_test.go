@@ -0,0 +1,109 @@
+package sc2client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+func TestResponseError_As(t *testing.T) {
+	req := &sc2proto.Request{
+		Request: &sc2proto.Request_CreateGame{
+			CreateGame: &sc2proto.RequestCreateGame{},
+		},
+	}
+	_, err := unpackResponse[*sc2proto.ResponseCreateGame](req, &sc2proto.Response{
+		Response: &sc2proto.Response_CreateGame{
+			CreateGame: &sc2proto.ResponseCreateGame{
+				Error:        sc2proto.ResponseCreateGame_InvalidMapPath.Enum(),
+				ErrorDetails: proto.String("map not found"),
+			},
+		},
+		Status: sc2proto.Status_launched.Enum(),
+	})
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		t.Errorf("expected ResponseError but got: %v", err)
+		return
+	}
+	code, ok := respErr.Code.(sc2proto.ResponseCreateGame_Error)
+	if !ok || code != sc2proto.ResponseCreateGame_InvalidMapPath {
+		t.Errorf("unexpected error code: %v", respErr.Code)
+		return
+	}
+	if respErr.Request != "create_game" || respErr.Details != "map not found" {
+		t.Errorf("unexpected response error: %s", respErr)
+		return
+	}
+
+	_, err = unpackResponse[*sc2proto.ResponseCreateGame](req, &sc2proto.Response{
+		Response: &sc2proto.Response_CreateGame{
+			CreateGame: &sc2proto.ResponseCreateGame{},
+		},
+	})
+	if err != nil {
+		t.Errorf("unpackResponse() error: %s", err)
+		return
+	}
+}
+
+func TestResponseError_Is(t *testing.T) {
+	req := &sc2proto.Request{
+		Request: &sc2proto.Request_Step{
+			Step: &sc2proto.RequestStep{},
+		},
+	}
+	_, err := unpackResponse[*sc2proto.ResponseStep](req, &sc2proto.Response{
+		Error:  []string{"Game has already ended"},
+		Status: sc2proto.Status_ended.Enum(),
+	})
+	if !errors.Is(err, ErrGameEnded) {
+		t.Errorf("expected ErrGameEnded but got: %v", err)
+		return
+	}
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || len(respErr.Errors) != 1 || respErr.Code != nil {
+		t.Errorf("unexpected response error: %v", err)
+		return
+	}
+}
+
+type readErrorTransport struct {
+	err error
+}
+
+func (t readErrorTransport) Read(ctx context.Context, rsp *sc2proto.Response) error {
+	return t.err
+}
+
+func (t readErrorTransport) Write(ctx context.Context, req *sc2proto.Request) error {
+	return nil
+}
+
+func TestConnectionClosedError_Unwrap(t *testing.T) {
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	rpcCli := NewRpcClient(readErrorTransport{err: readErr}, time.Minute)
+	<-rpcCli.Done()
+
+	err := rpcCli.Err()
+	if !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed but got: %v", err)
+		return
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr != readErr {
+		t.Errorf("expected the read error but got: %v", err)
+		return
+	}
+	var closedErr *ConnectionClosedError
+	if !errors.As(err, &closedErr) {
+		t.Errorf("expected ConnectionClosedError but got: %T", err)
+		return
+	}
+}
@@ -0,0 +1,197 @@
+package sc2client
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// DefaultCameraWidth is the width of the feature layer screen camera in world units, as
+// used by the game when none is given.
+const DefaultCameraWidth float32 = 24
+
+// Resolution is the size of a feature layer or rendered image in pixels.
+type Resolution struct {
+	Width  int32
+	Height int32
+}
+
+// SquareResolution returns a size x size resolution.
+func SquareResolution(size int32) Resolution {
+	return Resolution{Width: size, Height: size}
+}
+
+func (r Resolution) valid() bool {
+	return r.Width > 0 && r.Height > 0
+}
+
+func (r Resolution) size2DI() *sc2proto.Size2DI {
+	return &sc2proto.Size2DI{
+		X: proto.Int32(r.Width),
+		Y: proto.Int32(r.Height),
+	}
+}
+
+// CameraWidthForPixels returns the camera width in world units that maps pixelsPerUnit
+// screen pixels to each world unit.
+func CameraWidthForPixels(screen Resolution, pixelsPerUnit float32) float32 {
+	return float32(screen.Width) / pixelsPerUnit
+}
+
+// SpatialCamera sets up the screen and minimap of feature layers or rendered images.
+// CameraWidth, CropToPlayableArea and AllowCheatingLayers only apply to feature layers,
+// where a zero CameraWidth means DefaultCameraWidth.
+type SpatialCamera struct {
+	Screen              Resolution
+	Minimap             Resolution
+	CameraWidth         float32
+	CropToPlayableArea  bool
+	AllowCheatingLayers bool
+}
+
+// InterfaceOptions builds the observation and action interface a player joins with.
+type InterfaceOptions struct {
+	raw                   bool
+	score                 bool
+	featureLayer          *SpatialCamera
+	render                *SpatialCamera
+	showCloaked           bool
+	showBurrowedShadows   bool
+	showPlaceholders      bool
+	rawAffectsSelection   bool
+	rawCropToPlayableArea bool
+}
+
+func NewInterfaceOptions() *InterfaceOptions {
+	return &InterfaceOptions{}
+}
+
+// Raw enables raw unit observations and actions.
+func (o *InterfaceOptions) Raw() *InterfaceOptions {
+	o.raw = true
+	return o
+}
+
+// Score enables score observations.
+func (o *InterfaceOptions) Score() *InterfaceOptions {
+	o.score = true
+	return o
+}
+
+// FeatureLayer enables feature layer observations and actions.
+func (o *InterfaceOptions) FeatureLayer(camera SpatialCamera) *InterfaceOptions {
+	o.featureLayer = &camera
+	return o
+}
+
+// Render enables rendered image observations.
+func (o *InterfaceOptions) Render(camera SpatialCamera) *InterfaceOptions {
+	o.render = &camera
+	return o
+}
+
+// ShowCloaked shows some details of cloaked units, which are hidden otherwise.
+func (o *InterfaceOptions) ShowCloaked() *InterfaceOptions {
+	o.showCloaked = true
+	return o
+}
+
+// ShowBurrowedShadows shows some details of burrowed units that produce a shadow.
+func (o *InterfaceOptions) ShowBurrowedShadows() *InterfaceOptions {
+	o.showBurrowedShadows = true
+	return o
+}
+
+// ShowPlaceholders returns the buildings to be constructed as placeholder units.
+func (o *InterfaceOptions) ShowPlaceholders() *InterfaceOptions {
+	o.showPlaceholders = true
+	return o
+}
+
+// RawAffectsSelection keeps the selection made by raw actions instead of reverting it.
+func (o *InterfaceOptions) RawAffectsSelection() *InterfaceOptions {
+	o.rawAffectsSelection = true
+	return o
+}
+
+// RawCropToPlayableArea makes raw coordinates relative to the playable area.
+func (o *InterfaceOptions) RawCropToPlayableArea() *InterfaceOptions {
+	o.rawCropToPlayableArea = true
+	return o
+}
+
+func (o *InterfaceOptions) validate() error {
+	if !o.raw && !o.score && o.featureLayer == nil && o.render == nil {
+		return fmt.Errorf("no interface enabled, need one of raw, score, feature layer or render")
+	}
+	if !o.raw && (o.rawAffectsSelection || o.rawCropToPlayableArea) {
+		return fmt.Errorf("raw options need the raw interface")
+	}
+	if !o.raw && o.featureLayer == nil && (o.showCloaked || o.showBurrowedShadows || o.showPlaceholders) {
+		return fmt.Errorf("show options need the raw or feature layer interface")
+	}
+	if camera := o.featureLayer; camera != nil {
+		if !camera.Screen.valid() || !camera.Minimap.valid() {
+			return fmt.Errorf("invalid feature layer resolution: screen %v, minimap %v", camera.Screen, camera.Minimap)
+		}
+		if camera.CameraWidth < 0 {
+			return fmt.Errorf("invalid camera width: %f", camera.CameraWidth)
+		}
+	}
+	if camera := o.render; camera != nil {
+		if !camera.Screen.valid() || !camera.Minimap.valid() {
+			return fmt.Errorf("invalid render resolution: screen %v, minimap %v", camera.Screen, camera.Minimap)
+		}
+		if camera.CameraWidth != 0 || camera.CropToPlayableArea || camera.AllowCheatingLayers {
+			return fmt.Errorf("camera width, crop and cheating layers only apply to feature layers")
+		}
+	}
+	return nil
+}
+
+// Build validates the options and returns them as sent in RequestJoinGame.
+func (o *InterfaceOptions) Build() (*sc2proto.InterfaceOptions, error) {
+	err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+	options := &sc2proto.InterfaceOptions{
+		Raw:                   proto.Bool(o.raw),
+		Score:                 proto.Bool(o.score),
+		ShowCloaked:           proto.Bool(o.showCloaked),
+		ShowBurrowedShadows:   proto.Bool(o.showBurrowedShadows),
+		ShowPlaceholders:      proto.Bool(o.showPlaceholders),
+		RawAffectsSelection:   proto.Bool(o.rawAffectsSelection),
+		RawCropToPlayableArea: proto.Bool(o.rawCropToPlayableArea),
+	}
+	if camera := o.featureLayer; camera != nil {
+		width := camera.CameraWidth
+		if width == 0 {
+			width = DefaultCameraWidth
+		}
+		options.FeatureLayer = &sc2proto.SpatialCameraSetup{
+			Resolution:          camera.Screen.size2DI(),
+			MinimapResolution:   camera.Minimap.size2DI(),
+			Width:               proto.Float32(width),
+			CropToPlayableArea:  proto.Bool(camera.CropToPlayableArea),
+			AllowCheatingLayers: proto.Bool(camera.AllowCheatingLayers),
+		}
+	}
+	if camera := o.render; camera != nil {
+		options.Render = &sc2proto.SpatialCameraSetup{
+			Resolution:        camera.Screen.size2DI(),
+			MinimapResolution: camera.Minimap.size2DI(),
+		}
+	}
+	return options, nil
+}
+
+// interfaceOptions returns the options the player joins with, nil if not set.
+func (p *PlayerSetup) interfaceOptions() (*sc2proto.InterfaceOptions, error) {
+	if p.Interface == nil {
+		return nil, nil
+	}
+	return p.Interface.Build()
+}
@@ -0,0 +1,111 @@
+package sc2client
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
+)
+
+func TestInterfaceOptions_Build(t *testing.T) {
+	featureLayer := SpatialCamera{
+		Screen:  SquareResolution(84),
+		Minimap: SquareResolution(64),
+	}
+	options, err := NewInterfaceOptions().Raw().Score().FeatureLayer(featureLayer).RawCropToPlayableArea().Build()
+	if err != nil {
+		t.Errorf("Build() error: %s", err)
+		return
+	}
+	expected := &sc2proto.InterfaceOptions{
+		Raw:   proto.Bool(true),
+		Score: proto.Bool(true),
+		FeatureLayer: &sc2proto.SpatialCameraSetup{
+			Resolution:          &sc2proto.Size2DI{X: proto.Int32(84), Y: proto.Int32(84)},
+			MinimapResolution:   &sc2proto.Size2DI{X: proto.Int32(64), Y: proto.Int32(64)},
+			Width:               proto.Float32(DefaultCameraWidth),
+			CropToPlayableArea:  proto.Bool(false),
+			AllowCheatingLayers: proto.Bool(false),
+		},
+		ShowCloaked:           proto.Bool(false),
+		ShowBurrowedShadows:   proto.Bool(false),
+		ShowPlaceholders:      proto.Bool(false),
+		RawAffectsSelection:   proto.Bool(false),
+		RawCropToPlayableArea: proto.Bool(true),
+	}
+	if !proto.Equal(options, expected) {
+		t.Errorf("unexpected options: %v", options)
+	}
+	if width := CameraWidthForPixels(SquareResolution(84), 3.5); width != 24 {
+		t.Errorf("unexpected camera width: %f", width)
+	}
+
+	tests := []struct {
+		name    string
+		options *InterfaceOptions
+	}{
+		{name: "empty", options: NewInterfaceOptions()},
+		{name: "raw option without raw", options: NewInterfaceOptions().Score().RawAffectsSelection()},
+		{name: "show option without units", options: NewInterfaceOptions().Score().ShowCloaked()},
+		{name: "feature layer resolution", options: NewInterfaceOptions().FeatureLayer(SpatialCamera{Screen: SquareResolution(84)})},
+		{name: "render camera width", options: NewInterfaceOptions().Render(SpatialCamera{
+			Screen:      Resolution{Width: 640, Height: 480},
+			Minimap:     SquareResolution(128),
+			CameraWidth: 24,
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.options.Build()
+			if err == nil {
+				t.Errorf("expected Build() to fail")
+			}
+		})
+	}
+}
+
+func TestClient_HostGameInterface(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(ClientAttachOpts(server.Host(), server.Port()))
+	err := client.Init(ctx)
+	if err != nil {
+		t.Errorf("client.Init() error: %s", err)
+		return
+	}
+	defer client.Finalize()
+
+	players := []*PlayerSetup{
+		{
+			Type:      sc2proto.PlayerType_Participant,
+			Race:      sc2proto.Race_Terran,
+			Interface: NewInterfaceOptions().Raw().Score(),
+		},
+		{
+			Type:      sc2proto.PlayerType_Computer,
+			Race:      sc2proto.Race_Zerg,
+			Interface: NewInterfaceOptions().Raw(),
+		},
+	}
+	err = client.HostGame(ctx, &PortConfig{}, "Test.SC2Map", players, false)
+	if err == nil {
+		t.Errorf("expected HostGame() to reject interface options of a computer player")
+		return
+	}
+
+	players[1].Interface = nil
+	err = client.HostGame(ctx, &PortConfig{}, "Test.SC2Map", players, false)
+	if err != nil {
+		t.Errorf("client.HostGame() error: %s", err)
+		return
+	}
+	options := server.JoinGame().GetOptions()
+	if !options.GetRaw() || !options.GetScore() || options.GetFeatureLayer() != nil {
+		t.Errorf("unexpected interface options: %v", options)
+	}
+}
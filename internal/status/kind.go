@@ -0,0 +1,18 @@
+package status
+
+import (
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+var requestOneof = (&sc2proto.Request{}).ProtoReflect().Descriptor().Oneofs().ByName("request")
+
+// RequestKind returns the name of the oneof field set in req, e.g. "create_game", or ""
+// if none is set. Request and Response share the same oneof field names, so the kind of
+// a request is also the kind of the response expected for it.
+func RequestKind(req *sc2proto.Request) string {
+	field := req.ProtoReflect().WhichOneof(requestOneof)
+	if field == nil {
+		return ""
+	}
+	return string(field.Name())
+}
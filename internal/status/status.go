@@ -0,0 +1,51 @@
+// Package status holds the request state table of sc2api.proto, shared by the client
+// and the sc2test fake server so the two can't drift apart.
+package status
+
+import (
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// RequestStates lists the statuses each request is valid in, as documented in
+// sc2api.proto. Requests missing from the table are valid in any status.
+//
+// leave_game is also valid in ended: the table marks it as required when finishing a
+// multiplayer game, which has ended by then, and join_game is not valid in ended, so
+// leaving is the only way for a multiplayer client to get back to launched.
+var RequestStates = map[string][]sc2proto.Status{
+	"create_game":  {sc2proto.Status_launched, sc2proto.Status_ended},
+	"join_game":    {sc2proto.Status_launched, sc2proto.Status_init_game},
+	"restart_game": {sc2proto.Status_ended},
+	"start_replay": {sc2proto.Status_launched, sc2proto.Status_ended},
+	"leave_game":   {sc2proto.Status_in_game, sc2proto.Status_ended},
+	"quick_save":   {sc2proto.Status_in_game},
+	"quick_load":   {sc2proto.Status_in_game, sc2proto.Status_ended},
+	"game_info":    {sc2proto.Status_in_game, sc2proto.Status_in_replay, sc2proto.Status_ended},
+	"observation":  {sc2proto.Status_in_game, sc2proto.Status_in_replay, sc2proto.Status_ended},
+	"step":         {sc2proto.Status_in_game, sc2proto.Status_in_replay},
+	"action":       {sc2proto.Status_in_game},
+	"obs_action":   {sc2proto.Status_in_game, sc2proto.Status_in_replay},
+	"data":         {sc2proto.Status_in_game, sc2proto.Status_in_replay, sc2proto.Status_ended},
+	"query":        {sc2proto.Status_in_game, sc2proto.Status_in_replay, sc2proto.Status_ended},
+	"save_replay":  {sc2proto.Status_in_game, sc2proto.Status_ended},
+	"map_command":  {sc2proto.Status_in_game},
+	"debug":        {sc2proto.Status_in_game},
+}
+
+// Valid reports whether a request of kind is valid in status. Nothing is valid once the
+// game has quit.
+func Valid(kind string, status sc2proto.Status) bool {
+	if status == sc2proto.Status_quit {
+		return false
+	}
+	states, ok := RequestStates[kind]
+	if !ok {
+		return true
+	}
+	for _, s := range states {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
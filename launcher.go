@@ -2,89 +2,525 @@ package sc2client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
 )
 
 type Launcher struct {
-	clientPath   string
-	installDir   string
-	workDir      string
-	tempDir      string
-	clientHost   string
-	clientPort   int
-	displayMode  int
-	windowWidth  int
-	windowHeight int
-	windowX      int
-	windowY      int
-	cmd          *exec.Cmd
-}
-
-func NewLauncher(clientHost string, clientPort int, displayMode int, windowWidth int, windowHeight int, windowX int, windowY int) (*Launcher, error) {
-	clientPath, err := GetSC2ClientPath()
+	clientPath      string
+	installDir      string
+	workDir         string
+	tempDir         string
+	clientHost      string
+	clientPort      int
+	displayMode     int
+	windowWidth     int
+	windowHeight    int
+	windowX         int
+	windowY         int
+	headless        bool
+	baseBuild       uint32
+	dataVersion     string
+	eglPath         string
+	osMesaPath      string
+	extraArgs       []string
+	shutdownTimeout time.Duration
+	cmd             *exec.Cmd
+	exited          chan struct{}
+	exitErr         error
+	stopping        int32
+	outputWriter    io.Writer
+	outputLines     int
+	output          *processOutput
+}
+
+// LauncherOutputOpts streams the output of the game process to w, each line prefixed with
+// the pid. Lines are dropped rather than stalling the game if w falls behind.
+func LauncherOutputOpts(w io.Writer) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.outputWriter = w
+	}
+}
+
+// LauncherOutputLinesOpts sets how many of the last output lines are kept for diagnostics.
+func LauncherOutputLinesOpts(lines int) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.outputLines = lines
+	}
+}
+
+// LauncherDataVersionOpts selects the data version of the game, see LauncherReplayOpts.
+func LauncherDataVersionOpts(dataVersion string) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.dataVersion = dataVersion
+	}
+}
+
+// LauncherEGLPathOpts renders with the EGL library at path, or found by the dynamic
+// loader if path is a bare name like libEGL.so. Linux only.
+func LauncherEGLPathOpts(path string) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.eglPath = path
+	}
+}
+
+// LauncherOSMesaPathOpts renders with the OSMesa library at path, or found by the dynamic
+// loader if path is a bare name like libOSMesa.so. Linux only.
+func LauncherOSMesaPathOpts(path string) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.osMesaPath = path
+	}
+}
+
+// LauncherExtraArgsOpts appends arguments the launcher doesn't manage to the command line.
+func LauncherExtraArgsOpts(args ...string) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.extraArgs = append(launcher.extraArgs, args...)
+	}
+}
+
+// LauncherShutdownOpts sets how long each stage of Shutdown waits for the game to exit.
+func LauncherShutdownOpts(timeout time.Duration) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.shutdownTimeout = timeout
+	}
+}
+
+func LauncherBaseBuildOpts(baseBuild uint32) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.baseBuild = baseBuild
+	}
+}
+
+// LauncherReplayOpts selects the game version a replay was recorded with.
+func LauncherReplayOpts(info *sc2proto.ResponseReplayInfo) func(*Launcher) {
+	return func(launcher *Launcher) {
+		launcher.baseBuild = info.GetBaseBuild()
+		launcher.dataVersion = info.GetDataVersion()
+	}
+}
+
+func NewLauncher(clientHost string, clientPort int, displayMode int, windowWidth int, windowHeight int, windowX int, windowY int, opts ...func(*Launcher)) (*Launcher, error) {
+	launcher := &Launcher{
+		clientHost:      clientHost,
+		clientPort:      clientPort,
+		displayMode:     displayMode,
+		windowWidth:     windowWidth,
+		windowHeight:    windowHeight,
+		windowX:         windowX,
+		windowY:         windowY,
+		shutdownTimeout: 10 * time.Second,
+		outputLines:     100,
+	}
+	for _, option := range opts {
+		option(launcher)
+	}
+	clientPath, err := GetSC2ClientPathForBuild(launcher.baseBuild)
 	if err != nil {
-		return nil, fmt.Errorf("GetSC2ClientPath() error: %w", err)
+		return nil, fmt.Errorf("GetSC2ClientPathForBuild() error: %w", err)
 	}
-	clientInstallDir, err := filepath.Abs(filepath.Join(clientPath, "..", "..", ".."))
+	clientInstallDir, err := GetSC2InstallDir()
 	if err != nil {
-		return nil, fmt.Errorf("filepath.Abs() error: %w", err)
+		return nil, fmt.Errorf("GetSC2InstallDir() error: %w", err)
 	}
+	// the Linux headless build has no Support directories nor a window to configure
+	headless := runtime.GOOS == "linux"
 	workDir := filepath.Join(clientInstallDir, "Support")
-	if strings.Contains(filepath.Base(clientPath), "x64") {
+	if headless {
+		workDir = clientInstallDir
+	} else if strings.Contains(filepath.Base(clientPath), "x64") {
 		workDir = filepath.Join(clientInstallDir, "Support64")
 	}
 	tempDir, err := os.MkdirTemp(os.TempDir(), "SC2_")
 	if err != nil {
 		return nil, fmt.Errorf("os.MkdirTemp() error: %w", err)
 	}
-	return &Launcher{
-		clientPath:   clientPath,
-		installDir:   clientInstallDir,
-		workDir:      workDir,
-		tempDir:      tempDir,
-		clientHost:   clientHost,
-		clientPort:   clientPort,
-		displayMode:  displayMode,
-		windowWidth:  windowWidth,
-		windowHeight: windowHeight,
-		windowX:      windowX,
-		windowY:      windowY,
-	}, nil
+	launcher.clientPath = clientPath
+	launcher.installDir = clientInstallDir
+	launcher.workDir = workDir
+	launcher.tempDir = tempDir
+	launcher.headless = headless
+	err = launcher.validate()
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("launcher.validate() error: %w", err)
+	}
+	return launcher, nil
 }
 
-func (m *Launcher) StartProcess(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, m.clientPath,
+// managedArgs are the flags set by the launcher itself.
+var managedArgs = map[string]bool{
+	"-listen":       true,
+	"-port":         true,
+	"-displaymode":  true,
+	"-windowwidth":  true,
+	"-windowheight": true,
+	"-windowx":      true,
+	"-windowy":      true,
+	"-dataversion":  true,
+	"-eglpath":      true,
+	"-osmesapath":   true,
+	"-datadir":      true,
+	"-tempdir":      true,
+}
+
+var dataVersionPattern = regexp.MustCompile(`^[0-9A-F]{32}$`)
+
+func (m *Launcher) validate() error {
+	if m.clientPort <= 0 || m.clientPort > 65535 {
+		return fmt.Errorf("invalid port: %d", m.clientPort)
+	}
+	if !m.headless {
+		if m.displayMode != 0 && m.displayMode != 1 {
+			return fmt.Errorf("invalid display mode: %d", m.displayMode)
+		}
+		if m.windowWidth <= 0 || m.windowHeight <= 0 {
+			return fmt.Errorf("invalid window size: %dx%d", m.windowWidth, m.windowHeight)
+		}
+	}
+	if m.dataVersion != "" && !dataVersionPattern.MatchString(m.dataVersion) {
+		return fmt.Errorf("invalid data version: %s", m.dataVersion)
+	}
+	if m.eglPath != "" && m.osMesaPath != "" {
+		return fmt.Errorf("eglpath and osmesapath can't be used together")
+	}
+	for flag, path := range map[string]string{"eglpath": m.eglPath, "osmesapath": m.osMesaPath} {
+		if path == "" {
+			continue
+		}
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("%s is only supported on linux", flag)
+		}
+		// a bare name is resolved by the dynamic loader of the game, only paths can be checked
+		if !strings.ContainsRune(path, filepath.Separator) {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("invalid %s: %w", flag, err)
+		}
+	}
+	for _, arg := range m.extraArgs {
+		if arg == "" {
+			return fmt.Errorf("empty extra argument")
+		}
+		if managedArgs[strings.ToLower(arg)] {
+			return fmt.Errorf("extra argument %s is managed by the launcher", arg)
+		}
+	}
+	return nil
+}
+
+func (m *Launcher) args() []string {
+	args := []string{
 		"-listen", m.clientHost,
 		"-port", strconv.Itoa(m.clientPort),
-		"-displayMode", strconv.Itoa(m.displayMode),
-		"-windowwidth", strconv.Itoa(m.windowWidth),
-		"-windowheight", strconv.Itoa(m.windowHeight),
-		"-windowx", strconv.Itoa(m.windowX),
-		"-windowy", strconv.Itoa(m.windowY),
+	}
+	if !m.headless {
+		args = append(args,
+			"-displayMode", strconv.Itoa(m.displayMode),
+			"-windowwidth", strconv.Itoa(m.windowWidth),
+			"-windowheight", strconv.Itoa(m.windowHeight),
+			"-windowx", strconv.Itoa(m.windowX),
+			"-windowy", strconv.Itoa(m.windowY))
+	}
+	if m.dataVersion != "" {
+		args = append(args, "-dataVersion", m.dataVersion)
+	}
+	if m.eglPath != "" {
+		args = append(args, "-eglpath", m.eglPath)
+	}
+	if m.osMesaPath != "" {
+		args = append(args, "-osmesapath", m.osMesaPath)
+	}
+	args = append(args,
 		"-dataDir", m.installDir,
 		"-tempDir", m.tempDir,
 		"-verbose")
+	return append(args, m.extraArgs...)
+}
+
+// env returns the environment of the game process, nil to inherit ours. The Linux build
+// ships some of its shared libraries in <installDir>/Libs and needs them on
+// LD_LIBRARY_PATH to start.
+func (m *Launcher) env() []string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	libsDir := filepath.Join(m.installDir, "Libs")
+	if info, err := os.Stat(libsDir); err != nil || !info.IsDir() {
+		return nil
+	}
+	libraryPath := libsDir
+	if current := os.Getenv("LD_LIBRARY_PATH"); current != "" {
+		libraryPath += string(os.PathListSeparator) + current
+	}
+	return append(os.Environ(), "LD_LIBRARY_PATH="+libraryPath)
+}
+
+func (m *Launcher) StartProcess(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, m.clientPath, m.args()...)
 	cmd.Dir = m.workDir
-	err := cmd.Start()
+	cmd.Env = m.env()
+	output := newProcessOutput(m.outputWriter, m.outputLines)
+	pr, pw, err := os.Pipe()
 	if err != nil {
+		return fmt.Errorf("os.Pipe() error: %w", err)
+	}
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	err = cmd.Start()
+	_ = pw.Close()
+	if err != nil {
+		_ = pr.Close()
 		return fmt.Errorf("cmd.Run() error: %w", err)
 	}
+	output.start(cmd.Process.Pid, pr)
 	m.cmd = cmd
+	m.output = output
+	m.exited = make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		output.wait(2 * time.Second)
+		if atomic.LoadInt32(&m.stopping) == 0 {
+			m.exitErr = &ProcessExitError{
+				Pid:        cmd.Process.Pid,
+				ExitCode:   cmd.ProcessState.ExitCode(),
+				Err:        err,
+				LastLines:  output.LastLines(),
+				CrashDumps: findCrashDumps(m.tempDir),
+			}
+		}
+		close(m.exited)
+	}()
+	return nil
+}
+
+// Exited returns a channel that is closed when the game process exits.
+func (m *Launcher) Exited() <-chan struct{} {
+	return m.exited
+}
+
+// Err returns a ProcessExitError if the game process exited without being stopped.
+func (m *Launcher) Err() error {
+	if m.ProcessState() == nil || m.exitErr == nil {
+		return nil
+	}
+	return m.exitErr
+}
+
+// OutputLines returns the last lines the game process printed.
+func (m *Launcher) OutputLines() []string {
+	if m.output == nil {
+		return nil
+	}
+	return m.output.LastLines()
+}
+
+// findCrashDumps lists the crash dumps the game left in its temp dir.
+func findCrashDumps(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	var dumps []string
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		name := strings.ToLower(d.Name())
+		if filepath.Ext(name) == ".dmp" || strings.Contains(name, "crash") {
+			dumps = append(dumps, path)
+		}
+		return nil
+	})
+	return dumps
+}
+
+// WaitReady blocks until the game answers a ping on its port, retrying with backoff.
+// It fails early if the process exits before getting ready.
+func (m *Launcher) WaitReady(ctx context.Context) error {
+	backoff := 100 * time.Millisecond
+	for {
+		err := m.probe(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-m.exited:
+			timer.Stop()
+			err = m.Err()
+			if err == nil {
+				err = fmt.Errorf("game process stopped")
+			}
+			return fmt.Errorf("game process exited before ready: %w", err)
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("waiting for game ready: %w, last error: %v", ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > 2*time.Second {
+			backoff = 2 * time.Second
+		}
+	}
+}
+
+func (m *Launcher) probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addr := net.JoinHostPort(m.clientHost, strconv.Itoa(m.clientPort))
+	tcpConn, err := new(net.Dialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("net.Dial() error: %w", err)
+	}
+	_ = tcpConn.Close()
+
+	conn, err := DialSC2(ctx, m.clientHost, m.clientPort)
+	if err != nil {
+		return fmt.Errorf("DialSC2() error: %w", err)
+	}
+	defer conn.Close()
+	err = conn.Write(ctx, newPingRequest())
+	if err != nil {
+		return fmt.Errorf("conn.Write() error: %w", err)
+	}
+	rsp := &sc2proto.Response{}
+	err = conn.Read(ctx, rsp)
+	if err != nil {
+		return fmt.Errorf("conn.Read() error: %w", err)
+	}
+	if rsp.GetPing() == nil {
+		return fmt.Errorf("unexpected ping response: %v", rsp)
+	}
 	return nil
 }
 
+// MemoryUsage returns the resident memory of the game process in bytes. It's only
+// available on Linux.
+func (m *Launcher) MemoryUsage() (uint64, error) {
+	if runtime.GOOS != "linux" {
+		return 0, fmt.Errorf("memory usage is not supported on %s", runtime.GOOS)
+	}
+	statusPath := fmt.Sprintf("/proc/%d/status", m.cmd.Process.Pid)
+	content, err := os.ReadFile(statusPath)
+	if err != nil {
+		return 0, fmt.Errorf("os.ReadFile(%s) error: %w", statusPath, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "VmRSS:"))
+		if len(fields) != 2 || fields[1] != "kB" {
+			return 0, fmt.Errorf("unexpected VmRSS line: %q", line)
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("strconv.ParseUint(%s) error: %w", fields[0], err)
+		}
+		return kb * 1024, nil
+	}
+	return 0, fmt.Errorf("VmRSS not found in %s", statusPath)
+}
+
 func (m *Launcher) ProcessPid() int {
 	return m.cmd.Process.Pid
 }
 
+// StopProcess terminates the game process without asking it to quit first.
 func (m *Launcher) StopProcess() error {
-	if err := m.cmd.Process.Kill(); err != nil {
-		return fmt.Errorf("m.cmd.Process.Kill() error: %w", err)
+	return m.Shutdown(nil)
+}
+
+// Shutdown stops the game process and cleans up after it. The game is first asked to exit
+// by calling quit (e.g. sending RequestQuit), then sent SIGTERM and finally killed, each
+// stage waiting up to the shutdown timeout for the process to exit. It may be called again
+// once the process is gone.
+func (m *Launcher) Shutdown(quit func() error) error {
+	atomic.StoreInt32(&m.stopping, 1)
+	if m.cmd != nil && m.ProcessState() == nil {
+		if quit != nil {
+			err := quit()
+			if err != nil {
+				log.Println("[WARN] failed to quit game:", err)
+			} else if m.waitExit(m.shutdownTimeout) {
+				return m.cleanup()
+			}
+		}
+		err := m.cmd.Process.Signal(syscall.SIGTERM)
+		if err != nil || !m.waitExit(m.shutdownTimeout) {
+			log.Println("[WARN] killing game process:", m.cmd.Process.Pid)
+			err = m.cmd.Process.Kill()
+			if err != nil && !errors.Is(err, os.ErrProcessDone) {
+				return fmt.Errorf("m.cmd.Process.Kill() error: %w", err)
+			}
+			<-m.exited
+		}
+	}
+	return m.cleanup()
+}
+
+func (m *Launcher) waitExit(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-m.exited:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (m *Launcher) cleanup() error {
+	if m.tempDir == "" {
+		return nil
+	}
+	if m.Err() != nil {
+		log.Println("[WARN] game process crashed, keeping temp dir:", m.tempDir)
+		return nil
+	}
+	err := os.RemoveAll(m.tempDir)
+	if err != nil {
+		return fmt.Errorf("os.RemoveAll(%s) error: %w", m.tempDir, err)
 	}
 	return nil
 }
+
+// ProcessState returns the state of the exited game process, or nil if it's still running.
+func (m *Launcher) ProcessState() *os.ProcessState {
+	if m.cmd == nil {
+		return nil
+	}
+	select {
+	case <-m.exited:
+		return m.cmd.ProcessState
+	default:
+		return nil
+	}
+}
+
+// ExitCode returns the exit code of the game process, or -1 if it's still running or was
+// terminated by a signal.
+func (m *Launcher) ExitCode() int {
+	state := m.ProcessState()
+	if state == nil {
+		return -1
+	}
+	return state.ExitCode()
+}
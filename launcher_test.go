@@ -1,8 +1,22 @@
 package sc2client
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
 )
 
 func TestLauncher_StartProcess(t *testing.T) {
@@ -18,3 +32,321 @@ func TestLauncher_StartProcess(t *testing.T) {
 	}
 	t.Logf("launcher.StartProcess() return pid: %d", launcher.ProcessPid())
 }
+
+func TestLauncher_HeadlessArgs(t *testing.T) {
+	launcher := &Launcher{
+		clientHost: "127.0.0.1",
+		clientPort: 8167,
+		installDir: "/opt/StarCraftII",
+		tempDir:    "/tmp/SC2_test",
+		headless:   true,
+	}
+	args := launcher.args()
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-window") || arg == "-displayMode" {
+			t.Errorf("unexpected window argument for headless build: %v", args)
+			return
+		}
+	}
+	if strings.Join(args, " ") != "-listen 127.0.0.1 -port 8167 -dataDir /opt/StarCraftII -tempDir /tmp/SC2_test -verbose" {
+		t.Errorf("unexpected arguments: %v", args)
+	}
+}
+
+func TestNewLauncher_BaseBuild(t *testing.T) {
+	installDir := makeSC2Install(t, "70000", "75689")
+	t.Setenv(sc2PathEnv, installDir)
+
+	launcher, err := NewLauncher("127.0.0.1", 8167, 0, 1024, 768, 100, 100,
+		LauncherReplayOpts(&sc2proto.ResponseReplayInfo{
+			BaseBuild:   proto.Uint32(70000),
+			DataVersion: proto.String("94596A85191583AD2EBFAE28C5D532DB"),
+		}))
+	if err != nil {
+		t.Errorf("NewLauncher() error: %s", err)
+		return
+	}
+	defer os.RemoveAll(launcher.tempDir)
+	if launcher.clientPath != filepath.Join(installDir, "Versions", "Base70000", sc2ExecutableName()) {
+		t.Errorf("unexpected client path: %s", launcher.clientPath)
+		return
+	}
+	if !strings.Contains(strings.Join(launcher.args(), " "), "-dataVersion 94596A85191583AD2EBFAE28C5D532DB") {
+		t.Errorf("missing data version in arguments: %v", launcher.args())
+		return
+	}
+
+	_, err = NewLauncher("127.0.0.1", 8167, 0, 1024, 768, 100, 100, LauncherBaseBuildOpts(12345))
+	var buildErr *BuildNotFoundError
+	if !errors.As(err, &buildErr) {
+		t.Errorf("expected BuildNotFoundError but got: %v", err)
+		return
+	}
+	if len(buildErr.Installed) != 2 || buildErr.Installed[0] != 75689 || buildErr.Installed[1] != 70000 {
+		t.Errorf("unexpected installed builds: %v", buildErr.Installed)
+	}
+}
+
+func fakeSC2Executable(t *testing.T, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake game process needs a posix shell")
+	}
+	path := filepath.Join(t.TempDir(), "SC2_x64")
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error: %s", err)
+	}
+	return path
+}
+
+func TestLauncher_WaitReady(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+
+	launcher := &Launcher{
+		clientPath: fakeSC2Executable(t, "exec sleep 30"),
+		clientHost: server.Host(),
+		clientPort: server.Port(),
+		workDir:    t.TempDir(),
+		headless:   true,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := launcher.StartProcess(ctx)
+	if err != nil {
+		t.Errorf("StartProcess() error: %s", err)
+		return
+	}
+	defer launcher.StopProcess()
+	err = launcher.WaitReady(ctx)
+	if err != nil {
+		t.Errorf("WaitReady() error: %s", err)
+	}
+}
+
+func TestLauncher_WaitReadyExited(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error: %s", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	launcher := &Launcher{
+		clientPath: fakeSC2Executable(t, "exit 3"),
+		clientHost: "127.0.0.1",
+		clientPort: port,
+		workDir:    t.TempDir(),
+		headless:   true,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = launcher.StartProcess(ctx)
+	if err != nil {
+		t.Errorf("StartProcess() error: %s", err)
+		return
+	}
+	err = launcher.WaitReady(ctx)
+	if err == nil || ctx.Err() != nil {
+		t.Errorf("expected WaitReady() to fail early but got: %v", err)
+	}
+}
+
+func TestLauncher_Shutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		quit     bool
+		exitCode int
+	}{
+		{name: "quit", script: "trap 'kill $!; exit 0' INT; touch ready; sleep 30 & wait", quit: true, exitCode: 0},
+		{name: "terminate", script: "touch ready; exec sleep 30", exitCode: -1},
+		{name: "kill", script: "trap '' TERM; touch ready; exec sleep 30", exitCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			launcher := &Launcher{
+				clientPath:      fakeSC2Executable(t, tt.script),
+				workDir:         t.TempDir(),
+				tempDir:         t.TempDir(),
+				headless:        true,
+				shutdownTimeout: 500 * time.Millisecond,
+			}
+			err := launcher.StartProcess(context.Background())
+			if err != nil {
+				t.Errorf("StartProcess() error: %s", err)
+				return
+			}
+			for {
+				if _, err := os.Stat(filepath.Join(launcher.workDir, "ready")); err == nil {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if launcher.ProcessState() != nil {
+				t.Errorf("unexpected state of a running process")
+			}
+			var quit func() error
+			if tt.quit {
+				quit = func() error {
+					return launcher.cmd.Process.Signal(os.Interrupt)
+				}
+			}
+			err = launcher.Shutdown(quit)
+			if err != nil {
+				t.Errorf("Shutdown() error: %s", err)
+				return
+			}
+			if launcher.ProcessState() == nil {
+				t.Errorf("process not reaped")
+			}
+			if launcher.ExitCode() != tt.exitCode {
+				t.Errorf("unexpected exit code: %d", launcher.ExitCode())
+			}
+			if _, err := os.Stat(launcher.tempDir); !os.IsNotExist(err) {
+				t.Errorf("temp dir not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLauncher_ProcessExitError(t *testing.T) {
+	tempDir := t.TempDir()
+	var output bytes.Buffer
+	launcher := &Launcher{
+		clientPath:   fakeSC2Executable(t, "echo loading; echo starting; echo fatal error >&2; touch "+filepath.Join(tempDir, "SC2_x64.dmp")+"; exit 7"),
+		clientHost:   "127.0.0.1",
+		clientPort:   1,
+		workDir:      t.TempDir(),
+		tempDir:      tempDir,
+		headless:     true,
+		outputWriter: &output,
+		outputLines:  2,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := launcher.StartProcess(ctx)
+	if err != nil {
+		t.Errorf("StartProcess() error: %s", err)
+		return
+	}
+	err = launcher.WaitReady(ctx)
+	var exitErr *ProcessExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("expected ProcessExitError but got: %v", err)
+		return
+	}
+	if exitErr.Pid != launcher.ProcessPid() || exitErr.ExitCode != 7 {
+		t.Errorf("unexpected exit error: %v", exitErr)
+	}
+	if strings.Join(exitErr.LastLines, "|") != "starting|fatal error" {
+		t.Errorf("unexpected last lines: %v", exitErr.LastLines)
+	}
+	if len(exitErr.CrashDumps) != 1 {
+		t.Errorf("unexpected crash dumps: %v", exitErr.CrashDumps)
+	}
+	if !strings.HasPrefix(output.String(), fmt.Sprintf("[sc2 %d] loading\n", launcher.ProcessPid())) {
+		t.Errorf("unexpected output: %q", output.String())
+	}
+
+	err = launcher.Shutdown(nil)
+	if err != nil {
+		t.Errorf("Shutdown() error: %s", err)
+		return
+	}
+	if _, err := os.Stat(tempDir); err != nil {
+		t.Errorf("temp dir of a crashed process removed: %v", err)
+	}
+}
+
+func TestLauncher_LibraryPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("bundled libraries are only loaded on linux")
+	}
+	installDir := t.TempDir()
+	libsDir := filepath.Join(installDir, "Libs")
+	err := os.Mkdir(libsDir, 0o755)
+	if err != nil {
+		t.Fatalf("os.Mkdir() error: %s", err)
+	}
+	t.Setenv("LD_LIBRARY_PATH", "/usr/local/lib")
+	launcher := &Launcher{
+		clientPath:  fakeSC2Executable(t, "echo $LD_LIBRARY_PATH"),
+		clientHost:  "127.0.0.1",
+		clientPort:  1,
+		installDir:  installDir,
+		workDir:     t.TempDir(),
+		tempDir:     t.TempDir(),
+		headless:    true,
+		outputLines: 1,
+	}
+	err = launcher.StartProcess(context.Background())
+	if err != nil {
+		t.Errorf("StartProcess() error: %s", err)
+		return
+	}
+	<-launcher.Exited()
+	lines := launcher.OutputLines()
+	if len(lines) != 1 || lines[0] != libsDir+":/usr/local/lib" {
+		t.Errorf("unexpected LD_LIBRARY_PATH: %v", lines)
+	}
+}
+
+func TestNewLauncher_Options(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rendering options are only supported on linux")
+	}
+	installDir := makeSC2Install(t, "75689")
+	t.Setenv(sc2PathEnv, installDir)
+	eglPath := filepath.Join(t.TempDir(), "libEGL.so")
+	err := os.WriteFile(eglPath, nil, 0o644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() error: %s", err)
+	}
+
+	launcher, err := NewLauncher("127.0.0.1", 8167, 0, 1024, 768, 100, 100,
+		LauncherEGLPathOpts(eglPath),
+		LauncherDataVersionOpts("B89B5D6FA7CBF6452E721311BFBC6CB2"),
+		LauncherExtraArgsOpts("-realtime"))
+	if err != nil {
+		t.Errorf("NewLauncher() error: %s", err)
+		return
+	}
+	defer os.RemoveAll(launcher.tempDir)
+	args := strings.Join(launcher.args(), " ")
+	for _, want := range []string{"-eglpath " + eglPath, "-dataVersion B89B5D6FA7CBF6452E721311BFBC6CB2", "-verbose -realtime"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in arguments: %s", want, args)
+		}
+	}
+
+	// bare names are left to the dynamic loader
+	launcher, err = NewLauncher("127.0.0.1", 8167, 0, 1024, 768, 100, 100, LauncherOSMesaPathOpts("libOSMesa.so"))
+	if err != nil {
+		t.Errorf("NewLauncher() error: %s", err)
+		return
+	}
+	defer os.RemoveAll(launcher.tempDir)
+	if args := strings.Join(launcher.args(), " "); !strings.Contains(args, "-osmesapath libOSMesa.so") {
+		t.Errorf("missing osmesapath in arguments: %s", args)
+	}
+
+	tests := []struct {
+		name string
+		opts []func(*Launcher)
+	}{
+		{name: "both renderers", opts: []func(*Launcher){LauncherEGLPathOpts(eglPath), LauncherOSMesaPathOpts(eglPath)}},
+		{name: "missing library path", opts: []func(*Launcher){LauncherOSMesaPathOpts(filepath.Join(installDir, "libOSMesa.so"))}},
+		{name: "data version", opts: []func(*Launcher){LauncherDataVersionOpts("latest")}},
+		{name: "managed argument", opts: []func(*Launcher){LauncherExtraArgsOpts("-tempDir", "/tmp")}},
+		{name: "empty argument", opts: []func(*Launcher){LauncherExtraArgsOpts("")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLauncher("127.0.0.1", 8167, 0, 1024, 768, 100, 100, tt.opts...)
+			if err == nil {
+				t.Errorf("expected NewLauncher() to fail")
+			}
+		})
+	}
+}
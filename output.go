@@ -0,0 +1,154 @@
+package sc2client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// forwardLines is how many lines may wait for a slow writer before newer ones are dropped.
+const forwardLines = 1024
+
+// processOutput collects the output of the game process line by line, forwarding each
+// line prefixed with the pid and keeping the last ones in a ring buffer. Lines are written
+// from a goroutine of their own, so a slow writer never stalls the pipe and the game
+// blocked on it; lines it can't keep up with are dropped.
+type processOutput struct {
+	w       io.Writer
+	pid     int
+	pipe    *os.File
+	forward chan string
+	dropped int
+	read    chan struct{}
+	done    chan struct{}
+	mutex   sync.Mutex
+	partial []byte
+	lines   []string
+	next    int
+	full    bool
+}
+
+func newProcessOutput(w io.Writer, maxLines int) *processOutput {
+	if maxLines <= 0 {
+		maxLines = 1
+	}
+	return &processOutput{
+		w:     w,
+		read:  make(chan struct{}),
+		done:  make(chan struct{}),
+		lines: make([]string, maxLines),
+	}
+}
+
+// start reads the output of the process from pipe until it's closed on all sides.
+func (o *processOutput) start(pid int, pipe *os.File) {
+	o.pid = pid
+	o.pipe = pipe
+	if o.w != nil {
+		o.forward = make(chan string, forwardLines)
+		go o.writeLines()
+	}
+	go func() {
+		_, _ = io.Copy(o, pipe)
+		_ = pipe.Close()
+		o.flush()
+		close(o.read)
+		if o.forward == nil {
+			close(o.done)
+			return
+		}
+		if o.dropped > 0 {
+			o.forward <- fmt.Sprintf("(%d lines dropped)", o.dropped)
+		}
+		close(o.forward)
+	}()
+}
+
+// writeLines writes the forwarded lines to w until the output is read to the end.
+func (o *processOutput) writeLines() {
+	defer close(o.done)
+	for line := range o.forward {
+		// write errors are dropped too, the output is only informational
+		_, _ = fmt.Fprintf(o.w, "[sc2 %d] %s\n", o.pid, line)
+	}
+}
+
+// wait waits for the output to be read and written after the process exited. Children of
+// the process may keep the pipe open, so it's closed after delay; a writer still busy by
+// then is not waited for.
+func (o *processOutput) wait(delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-o.done:
+	case <-timer.C:
+		_ = o.pipe.Close()
+		<-o.read
+	}
+}
+
+func (o *processOutput) Write(p []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.addLine(string(bytes.TrimRight(o.partial[:i], "\r")))
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (o *processOutput) addLine(line string) {
+	o.lines[o.next] = line
+	o.next++
+	if o.next >= len(o.lines) {
+		o.next = 0
+		o.full = true
+	}
+	if o.forward == nil {
+		return
+	}
+	// once the writer caught up, the gap is marked where it happened
+	if o.dropped > 0 {
+		select {
+		case o.forward <- fmt.Sprintf("(%d lines dropped)", o.dropped):
+			o.dropped = 0
+		default:
+			o.dropped++
+			return
+		}
+	}
+	select {
+	case o.forward <- line:
+	default:
+		o.dropped++
+	}
+}
+
+// flush emits the last line if the process exited without terminating it.
+func (o *processOutput) flush() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if len(o.partial) > 0 {
+		o.addLine(string(o.partial))
+		o.partial = nil
+	}
+}
+
+// LastLines returns the buffered lines, oldest first.
+func (o *processOutput) LastLines() []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	var lines []string
+	if o.full {
+		lines = append(lines, o.lines[o.next:]...)
+	}
+	return append(lines, o.lines[:o.next]...)
+}
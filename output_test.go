@@ -0,0 +1,72 @@
+package sc2client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks every write until unblock is closed.
+type blockingWriter struct {
+	unblock chan struct{}
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func TestProcessOutput_SlowWriter(t *testing.T) {
+	w := &blockingWriter{unblock: make(chan struct{})}
+	output := newProcessOutput(w, 2)
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error: %s", err)
+	}
+	output.start(1, pr)
+
+	// the pipe keeps being drained while the writer is stuck
+	lines := forwardLines * 2
+	written := make(chan error, 1)
+	go func() {
+		for i := 0; i < lines; i++ {
+			if _, err := fmt.Fprintf(pw, "line %d\n", i); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- pw.Close()
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Errorf("pw.Write() error: %s", err)
+			return
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("output not drained while the writer blocks")
+		return
+	}
+	<-output.read
+	if last := strings.Join(output.LastLines(), "|"); last != fmt.Sprintf("line %d|line %d", lines-2, lines-1) {
+		t.Errorf("unexpected last lines: %s", last)
+	}
+
+	close(w.unblock)
+	output.wait(5 * time.Second)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// the lines queued before the writer got stuck are written, the gap is marked after them
+	out := w.buf.String()
+	if !strings.HasPrefix(out, "[sc2 1] line 0\n") || !strings.HasSuffix(out, " lines dropped)\n") {
+		t.Errorf("unexpected output: %.100q", out)
+	}
+}
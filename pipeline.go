@@ -0,0 +1,221 @@
+package sc2client
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// Pipeline queues several requests, writes them back-to-back and then collects their
+// responses, saving a round trip per request. The game handles requests strictly in
+// order, so e.g. Action + Step + Observation behave exactly like three sequential calls.
+type Pipeline struct {
+	rpc   *RpcClient
+	calls []pipelineCall
+}
+
+type pipelineCall interface {
+	request() *sc2proto.Request
+	resolve(resp *sc2proto.Response, err error) error
+}
+
+type PipelineResult[T proto.Message] struct {
+	req  *sc2proto.Request
+	resp T
+	err  error
+}
+
+func (r *PipelineResult[T]) request() *sc2proto.Request {
+	return r.req
+}
+
+func (r *PipelineResult[T]) resolve(resp *sc2proto.Response, err error) error {
+	if err != nil {
+		r.err = err
+		return err
+	}
+	r.resp, r.err = unpackResponse[T](r.req, resp)
+	return r.err
+}
+
+// Get returns the response of the queued request. It is only valid after Exec returned.
+func (r *PipelineResult[T]) Get() (T, error) {
+	return r.resp, r.err
+}
+
+func (c *RpcClient) Pipeline() *Pipeline {
+	return &Pipeline{
+		rpc: c,
+	}
+}
+
+func queue[T proto.Message](p *Pipeline, req *sc2proto.Request) *PipelineResult[T] {
+	result := &PipelineResult[T]{
+		req: req,
+	}
+	p.calls = append(p.calls, result)
+	return result
+}
+
+func (p *Pipeline) Len() int {
+	return len(p.calls)
+}
+
+// Exec sends all queued requests and waits for their responses in order. Every result is
+// resolved when Exec returns; the returned error is the first one met, if any.
+//
+// Only the first request is checked against the status of the game, as SendRequest does:
+// the status the later ones run in is only known once the earlier ones were handled, e.g.
+// LeaveGame + CreateGame, so an invalid one fails with the error of the game instead.
+func (p *Pipeline) Exec(ctx context.Context) error {
+	calls := p.calls
+	p.calls = nil
+	ids := make([]uint32, len(calls))
+	var firstErr error
+	sent := 0
+	for ; sent < len(calls); sent++ {
+		var id uint32
+		var err error
+		if sent == 0 {
+			id, err = p.rpc.SendRequest(ctx, calls[sent].request())
+		} else {
+			id, err = p.rpc.writeRequest(ctx, calls[sent].request())
+		}
+		if err != nil {
+			firstErr = fmt.Errorf("p.rpc.SendRequest() error: %w", err)
+			break
+		}
+		ids[sent] = id
+	}
+	for i := sent; i < len(calls); i++ {
+		_ = calls[i].resolve(nil, firstErr)
+	}
+	for i := 0; i < sent; i++ {
+		resp, err := p.rpc.WaitForResponse(ctx, ids[i])
+		if err != nil {
+			err = fmt.Errorf("p.rpc.WaitForResponse() error: %w", err)
+		}
+		err = calls[i].resolve(resp, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *Pipeline) Ping() *PipelineResult[*sc2proto.ResponsePing] {
+	return queue[*sc2proto.ResponsePing](p, newPingRequest())
+}
+
+func (p *Pipeline) CreateGame(req *sc2proto.RequestCreateGame) *PipelineResult[*sc2proto.ResponseCreateGame] {
+	return queue[*sc2proto.ResponseCreateGame](p, newCreateGameRequest(req))
+}
+
+func (p *Pipeline) JoinGame(req *sc2proto.RequestJoinGame) *PipelineResult[*sc2proto.ResponseJoinGame] {
+	return queue[*sc2proto.ResponseJoinGame](p, newJoinGameRequest(req))
+}
+
+func (p *Pipeline) RestartGame(req *sc2proto.RequestRestartGame) *PipelineResult[*sc2proto.ResponseRestartGame] {
+	return queue[*sc2proto.ResponseRestartGame](p, newRestartGameRequest(req))
+}
+
+func (p *Pipeline) LeaveGame(req *sc2proto.RequestLeaveGame) *PipelineResult[*sc2proto.ResponseLeaveGame] {
+	return queue[*sc2proto.ResponseLeaveGame](p, newLeaveGameRequest(req))
+}
+
+// Quit queues a quit request. The game closes the connection instead of answering it, so
+// its result is usually an error.
+func (p *Pipeline) Quit(req *sc2proto.RequestQuit) *PipelineResult[*sc2proto.ResponseQuit] {
+	return queue[*sc2proto.ResponseQuit](p, newQuitRequest(req))
+}
+
+func (p *Pipeline) Step(req *sc2proto.RequestStep) *PipelineResult[*sc2proto.ResponseStep] {
+	return queue[*sc2proto.ResponseStep](p, newStepRequest(req))
+}
+
+func (p *Pipeline) GameInfo(req *sc2proto.RequestGameInfo) *PipelineResult[*sc2proto.ResponseGameInfo] {
+	return queue[*sc2proto.ResponseGameInfo](p, newGameInfoRequest(req))
+}
+
+func (p *Pipeline) Action(req *sc2proto.RequestAction) *PipelineResult[*sc2proto.ResponseAction] {
+	return queue[*sc2proto.ResponseAction](p, newActionRequest(req))
+}
+
+func (p *Pipeline) Observation(req *sc2proto.RequestObservation) *PipelineResult[*sc2proto.ResponseObservation] {
+	return queue[*sc2proto.ResponseObservation](p, newObservationRequest(req))
+}
+
+func (p *Pipeline) QuickSave(req *sc2proto.RequestQuickSave) *PipelineResult[*sc2proto.ResponseQuickSave] {
+	return queue[*sc2proto.ResponseQuickSave](p, newQuickSaveRequest(req))
+}
+
+func (p *Pipeline) QuickLoad(req *sc2proto.RequestQuickLoad) *PipelineResult[*sc2proto.ResponseQuickLoad] {
+	return queue[*sc2proto.ResponseQuickLoad](p, newQuickLoadRequest(req))
+}
+
+func (p *Pipeline) ObsAction(req *sc2proto.RequestObserverAction) *PipelineResult[*sc2proto.ResponseObserverAction] {
+	return queue[*sc2proto.ResponseObserverAction](p, newObsActionRequest(req))
+}
+
+func (p *Pipeline) Data(req *sc2proto.RequestData) *PipelineResult[*sc2proto.ResponseData] {
+	return queue[*sc2proto.ResponseData](p, newDataRequest(req))
+}
+
+func (p *Pipeline) Query(req *sc2proto.RequestQuery) *PipelineResult[*sc2proto.ResponseQuery] {
+	return queue[*sc2proto.ResponseQuery](p, newQueryRequest(req))
+}
+
+func (p *Pipeline) SaveReplay(req *sc2proto.RequestSaveReplay) *PipelineResult[*sc2proto.ResponseSaveReplay] {
+	return queue[*sc2proto.ResponseSaveReplay](p, newSaveReplayRequest(req))
+}
+
+func (p *Pipeline) MapCommand(req *sc2proto.RequestMapCommand) *PipelineResult[*sc2proto.ResponseMapCommand] {
+	return queue[*sc2proto.ResponseMapCommand](p, newMapCommandRequest(req))
+}
+
+func (p *Pipeline) ReplayInfo(req *sc2proto.RequestReplayInfo) *PipelineResult[*sc2proto.ResponseReplayInfo] {
+	return queue[*sc2proto.ResponseReplayInfo](p, newReplayInfoRequest(req))
+}
+
+func (p *Pipeline) AvailableMaps(req *sc2proto.RequestAvailableMaps) *PipelineResult[*sc2proto.ResponseAvailableMaps] {
+	return queue[*sc2proto.ResponseAvailableMaps](p, newAvailableMapsRequest(req))
+}
+
+func (p *Pipeline) SaveMap(req *sc2proto.RequestSaveMap) *PipelineResult[*sc2proto.ResponseSaveMap] {
+	return queue[*sc2proto.ResponseSaveMap](p, newSaveMapRequest(req))
+}
+
+func (p *Pipeline) StartReplay(req *sc2proto.RequestStartReplay) *PipelineResult[*sc2proto.ResponseStartReplay] {
+	return queue[*sc2proto.ResponseStartReplay](p, newStartReplayRequest(req))
+}
+
+func (p *Pipeline) Debug(req *sc2proto.RequestDebug) *PipelineResult[*sc2proto.ResponseDebug] {
+	return queue[*sc2proto.ResponseDebug](p, newDebugRequest(req))
+}
+
+// ActAndObserve sends action (if not nil), step (if not nil, i.e. not in realtime mode) and
+// observation in a single pipeline, the usual round trip of a step-mode agent.
+func (c *RpcClient) ActAndObserve(ctx context.Context, action *sc2proto.RequestAction, step *sc2proto.RequestStep, observation *sc2proto.RequestObservation) (*sc2proto.ResponseAction, *sc2proto.ResponseObservation, error) {
+	p := c.Pipeline()
+	var actionResult *PipelineResult[*sc2proto.ResponseAction]
+	if action != nil {
+		actionResult = p.Action(action)
+	}
+	if step != nil {
+		p.Step(step)
+	}
+	observationResult := p.Observation(observation)
+	err := p.Exec(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("p.Exec() error: %w", err)
+	}
+	var actionResp *sc2proto.ResponseAction
+	if actionResult != nil {
+		actionResp, _ = actionResult.Get()
+	}
+	observationResp, _ := observationResult.Get()
+	return actionResp, observationResp, nil
+}
@@ -0,0 +1,146 @@
+package sc2client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/internal/status"
+	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
+)
+
+func TestPipeline_Exec(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	server.SetStatus(sc2proto.Status_in_game)
+	server.Handle("available_maps", func(s *sc2test.Server, req *sc2proto.Request) *sc2proto.Response {
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_AvailableMaps{
+				AvailableMaps: &sc2proto.ResponseAvailableMaps{LocalMapPaths: []string{"Test.SC2Map"}},
+			},
+		}
+	})
+	rpcCli := NewRpcClient(dialServer(t, server), 5*time.Second)
+
+	p := rpcCli.Pipeline()
+	action := p.Action(&sc2proto.RequestAction{Actions: []*sc2proto.Action{{}}})
+	step := p.Step(&sc2proto.RequestStep{Count: proto.Uint32(8)})
+	observation := p.Observation(&sc2proto.RequestObservation{})
+	err := p.Exec(context.Background())
+	if err != nil {
+		t.Errorf("p.Exec() error: %s", err)
+		return
+	}
+	actionResp, err := action.Get()
+	if err != nil || len(actionResp.GetResult()) != 1 {
+		t.Errorf("unexpected action result: %v, %v", actionResp, err)
+		return
+	}
+	stepResp, err := step.Get()
+	if err != nil || stepResp.GetSimulationLoop() != 8 {
+		t.Errorf("unexpected step result: %v, %v", stepResp, err)
+		return
+	}
+	observationResp, err := observation.Get()
+	if err != nil || observationResp.GetObservation().GetGameLoop() != 8 {
+		t.Errorf("unexpected observation result: %v, %v", observationResp, err)
+		return
+	}
+	var received []string
+	for _, req := range server.Requests() {
+		received = append(received, status.RequestKind(req))
+	}
+	if len(received) != 3 || received[0] != "action" || received[1] != "step" || received[2] != "observation" {
+		t.Errorf("unexpected request order: %v", received)
+		return
+	}
+
+	server.InjectError("query", "query failed")
+	p = rpcCli.Pipeline()
+	query := p.Query(&sc2proto.RequestQuery{})
+	observation = p.Observation(&sc2proto.RequestObservation{})
+	err = p.Exec(context.Background())
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.Request != "query" {
+		t.Errorf("expected query ResponseError but got: %v", err)
+		return
+	}
+	if _, err := query.Get(); err == nil {
+		t.Errorf("expected query error")
+		return
+	}
+	if _, err := observation.Get(); err != nil {
+		t.Errorf("unexpected observation error: %s", err)
+		return
+	}
+
+	p = rpcCli.Pipeline()
+	ping := p.Ping()
+	maps := p.AvailableMaps(&sc2proto.RequestAvailableMaps{})
+	err = p.Exec(context.Background())
+	if err != nil {
+		t.Errorf("p.Exec() error: %s", err)
+		return
+	}
+	if pingResp, err := ping.Get(); err != nil || pingResp.GetBaseBuild() != 75689 {
+		t.Errorf("unexpected ping result: %v, %v", pingResp, err)
+		return
+	}
+	if mapsResp, err := maps.Get(); err != nil || len(mapsResp.GetLocalMapPaths()) != 1 {
+		t.Errorf("unexpected available maps result: %v, %v", mapsResp, err)
+		return
+	}
+
+	_, observationResp, err = rpcCli.ActAndObserve(context.Background(), nil, &sc2proto.RequestStep{}, &sc2proto.RequestObservation{})
+	if err != nil || observationResp.GetObservation().GetGameLoop() != 9 {
+		t.Errorf("unexpected ActAndObserve() result: %v, %v", observationResp, err)
+		return
+	}
+}
+
+func TestPipeline_ExecStatus(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	server.SetStatus(sc2proto.Status_in_game)
+	rpcCli := NewRpcClient(dialServer(t, server), 5*time.Second)
+	ctx := context.Background()
+	if _, err := rpcCli.Ping(ctx); err != nil {
+		t.Errorf("rpcCli.Ping() error: %s", err)
+		return
+	}
+
+	// create_game is not valid in_game, but it is after the leave_game queued before it
+	p := rpcCli.Pipeline()
+	p.LeaveGame(&sc2proto.RequestLeaveGame{})
+	p.CreateGame(&sc2proto.RequestCreateGame{
+		Map: &sc2proto.RequestCreateGame_LocalMap{
+			LocalMap: &sc2proto.LocalMap{MapPath: proto.String("Test.SC2Map")},
+		},
+		PlayerSetup: []*sc2proto.PlayerSetup{
+			{Type: sc2proto.PlayerType_Participant.Enum()},
+			{Type: sc2proto.PlayerType_Computer.Enum()},
+		},
+	})
+	if err := p.Exec(ctx); err != nil {
+		t.Errorf("p.Exec() error: %s", err)
+		return
+	}
+	if server.Status() != sc2proto.Status_init_game {
+		t.Errorf("unexpected server status: %s", server.Status())
+		return
+	}
+
+	// the first request is still checked before anything is sent
+	p = rpcCli.Pipeline()
+	p.RestartGame(&sc2proto.RequestRestartGame{})
+	p.Ping()
+	var statusErr *StatusError
+	if err := p.Exec(ctx); !errors.As(err, &statusErr) {
+		t.Errorf("expected StatusError but got: %v", err)
+		return
+	}
+}
@@ -0,0 +1,366 @@
+package sc2client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// LauncherPool keeps a number of initialized game instances warm, each launched on its own
+// port, and hands them out for hosting or joining games. Instances are health-checked with
+// a ping when acquired and recycled after a number of games or when their memory grows.
+type LauncherPool struct {
+	newClient       func() *Client
+	size            int
+	maxGames        int
+	maxMemoryGrowth uint64
+	pingTimeout     time.Duration
+	mutex           sync.Mutex
+	idle            []*Client
+	entries         map[*Client]*poolEntry
+	starting        int
+	changed         chan struct{}
+	closed          bool
+	wg              sync.WaitGroup
+	ctx             context.Context
+	cancel          context.CancelFunc
+}
+
+type poolEntry struct {
+	games      int
+	baseMemory uint64
+}
+
+// PoolClientOpts sets how the pool creates its Clients.
+func PoolClientOpts(newClient func() *Client) func(*LauncherPool) {
+	return func(pool *LauncherPool) {
+		pool.newClient = newClient
+	}
+}
+
+// PoolMaxGamesOpts recycles an instance after it played games games, 0 meaning never.
+func PoolMaxGamesOpts(games int) func(*LauncherPool) {
+	return func(pool *LauncherPool) {
+		pool.maxGames = games
+	}
+}
+
+// PoolMaxMemoryGrowthOpts recycles an instance once its resident memory grew by more than
+// bytes since it was launched, 0 meaning never. It only works on Linux.
+func PoolMaxMemoryGrowthOpts(bytes uint64) func(*LauncherPool) {
+	return func(pool *LauncherPool) {
+		pool.maxMemoryGrowth = bytes
+	}
+}
+
+// NewLauncherPool launches size game instances and waits for them to get ready. The game
+// processes belong to the pool, ctx is only used to wait for them.
+func NewLauncherPool(ctx context.Context, size int, opts ...func(*LauncherPool)) (*LauncherPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid pool size: %d", size)
+	}
+	pool := &LauncherPool{
+		newClient: func() *Client {
+			return NewClient()
+		},
+		size:        size,
+		pingTimeout: 5 * time.Second,
+		entries:     make(map[*Client]*poolEntry),
+		changed:     make(chan struct{}),
+	}
+	for _, option := range opts {
+		option(pool)
+	}
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+
+	launches := make([]*poolLaunch, size)
+	pool.mutex.Lock()
+	for i := range launches {
+		launches[i] = pool.launchLocked()
+	}
+	pool.mutex.Unlock()
+	errors := runParallel(size, func(index int) error {
+		client, err := launches[index].wait(ctx)
+		if err != nil {
+			return err
+		}
+		pool.addIdle(client)
+		return nil
+	})
+	for _, err := range errors {
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("launch.wait() error: %w", err)
+		}
+	}
+	return pool, nil
+}
+
+func (p *LauncherPool) start() (*Client, error) {
+	client := p.newClient()
+	err := client.Init(p.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client.Init() error: %w", err)
+	}
+	entry := &poolEntry{}
+	if client.launcher != nil {
+		entry.baseMemory, _ = client.launcher.MemoryUsage()
+	}
+	p.mutex.Lock()
+	p.entries[client] = entry
+	p.mutex.Unlock()
+	return client, nil
+}
+
+// notifyLocked wakes up the callers of Acquire waiting for an instance.
+func (p *LauncherPool) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Acquire takes an idle instance out of the pool, waiting for one if they are all in use.
+// It must be handed back with Release. An instance launched for the caller outlives ctx,
+// joining the idle ones if the caller stops waiting.
+func (p *LauncherPool) Acquire(ctx context.Context) (*Client, error) {
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if len(p.idle) > 0 {
+			client := p.idle[len(p.idle)-1]
+			p.idle = p.idle[:len(p.idle)-1]
+			p.mutex.Unlock()
+			err := p.healthCheck(ctx, client)
+			if err == nil {
+				return client, nil
+			}
+			log.Println("[WARN] recycling unhealthy game instance:", err)
+			p.recycle(client)
+			continue
+		}
+		if len(p.entries)+p.starting < p.size {
+			launch := p.launchLocked()
+			p.mutex.Unlock()
+			client, err := launch.wait(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("launch.wait() error: %w", err)
+			}
+			return client, nil
+		}
+		changed := p.changed
+		p.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *LauncherPool) healthCheck(ctx context.Context, client *Client) error {
+	if client.gone() {
+		return fmt.Errorf("game instance exited: %v", client.Err())
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.pingTimeout)
+	defer cancel()
+	_, err := client.rpc.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("client.rpc.Ping() error: %w", err)
+	}
+	if status := client.rpc.Status(); status != sc2proto.Status_launched {
+		return fmt.Errorf("game instance not ready for a new game, status: %s", status)
+	}
+	return nil
+}
+
+// leaveGame gets an instance out of the game it played, back to launched for the next
+// HostGame or JoinGame.
+func (p *LauncherPool) leaveGame(client *Client) error {
+	ctx, cancel := context.WithTimeout(p.ctx, p.pingTimeout)
+	defer cancel()
+	err := client.leaveGame(ctx)
+	if err != nil {
+		return fmt.Errorf("client.leaveGame() error: %w", err)
+	}
+	if status := client.rpc.Status(); status != sc2proto.Status_launched {
+		return fmt.Errorf("game instance not ready for a new game, status: %s", status)
+	}
+	return nil
+}
+
+// Release hands an instance back to the pool after a game, leaving the game if needed.
+// Instances that died, played their maximum number of games, grew too big or can't leave
+// their game are shut down and replaced.
+func (p *LauncherPool) Release(client *Client) {
+	p.mutex.Lock()
+	entry, ok := p.entries[client]
+	if !ok {
+		p.mutex.Unlock()
+		return
+	}
+	entry.games++
+	closed := p.closed
+	p.mutex.Unlock()
+
+	reason := p.recycleReason(client, entry)
+	if !closed && reason == "" {
+		if err := p.leaveGame(client); err != nil {
+			reason = err.Error()
+		}
+	}
+	if closed || reason != "" {
+		if reason != "" {
+			log.Println("recycling game instance:", reason)
+		}
+		p.recycle(client)
+		return
+	}
+	p.mutex.Lock()
+	p.idle = append(p.idle, client)
+	p.notifyLocked()
+	p.mutex.Unlock()
+}
+
+func (p *LauncherPool) recycleReason(client *Client, entry *poolEntry) string {
+	if client.gone() {
+		return "game instance exited"
+	}
+	if p.maxGames > 0 && entry.games >= p.maxGames {
+		return fmt.Sprintf("played %d games", entry.games)
+	}
+	if p.maxMemoryGrowth > 0 && client.launcher != nil && entry.baseMemory > 0 {
+		memory, err := client.launcher.MemoryUsage()
+		if err == nil && memory > entry.baseMemory+p.maxMemoryGrowth {
+			return fmt.Sprintf("memory grew from %d to %d bytes", entry.baseMemory, memory)
+		}
+	}
+	return ""
+}
+
+// recycle shuts an instance down and launches a replacement in the background, so the
+// pool stays warm.
+func (p *LauncherPool) recycle(client *Client) {
+	p.drop(client)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		p.notifyLocked()
+		return
+	}
+	close(p.launchLocked().gaveUp)
+}
+
+// drop shuts an instance down and forgets it. Once a closed pool has no instances left,
+// its context is cancelled, aborting the launches still in progress.
+func (p *LauncherPool) drop(client *Client) {
+	client.Finalize()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.entries, client)
+	p.cancelIfDrainedLocked()
+}
+
+func (p *LauncherPool) cancelIfDrainedLocked() {
+	if p.closed && len(p.entries) == 0 {
+		p.cancel()
+	}
+}
+
+// addIdle hands a launched instance to the pool, or shuts it down if the pool is closed.
+func (p *LauncherPool) addIdle(client *Client) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		p.drop(client)
+		return
+	}
+	p.idle = append(p.idle, client)
+	p.notifyLocked()
+	p.mutex.Unlock()
+}
+
+type launchResult struct {
+	client *Client
+	err    error
+}
+
+// poolLaunch is an instance launched in the background with the context of the pool, so
+// the process isn't tied to the context of the caller waiting for it.
+type poolLaunch struct {
+	result chan launchResult
+	gaveUp chan struct{}
+}
+
+// launchLocked starts launching an instance. It becomes idle if nobody waits for it.
+func (p *LauncherPool) launchLocked() *poolLaunch {
+	launch := &poolLaunch{
+		result: make(chan launchResult),
+		gaveUp: make(chan struct{}),
+	}
+	p.starting++
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		client, err := p.start()
+		p.mutex.Lock()
+		p.starting--
+		p.notifyLocked()
+		p.mutex.Unlock()
+		select {
+		case launch.result <- launchResult{client: client, err: err}:
+		case <-launch.gaveUp:
+			if err != nil {
+				log.Println("[WARN] failed to launch game instance:", err)
+				return
+			}
+			p.addIdle(client)
+		}
+	}()
+	return launch
+}
+
+// wait waits for the instance to get ready, giving it up to the pool if ctx is done first.
+func (l *poolLaunch) wait(ctx context.Context) (*Client, error) {
+	select {
+	case result := <-l.result:
+		return result.client, result.err
+	case <-ctx.Done():
+		close(l.gaveUp)
+		return nil, ctx.Err()
+	}
+}
+
+// Size returns the number of instances the pool keeps.
+func (p *LauncherPool) Size() int {
+	return p.size
+}
+
+// Close shuts the idle instances down and waits for replacements being launched. The
+// instances still in use are shut down when released, and the last one to go cancels the
+// launches still in progress.
+func (p *LauncherPool) Close() {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.cancelIfDrainedLocked()
+	p.notifyLocked()
+	p.mutex.Unlock()
+
+	runParallel(len(idle), func(index int) error {
+		p.drop(idle[index])
+		return nil
+	})
+	p.wg.Wait()
+}
@@ -0,0 +1,165 @@
+package sc2client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
+)
+
+// serverPool makes a pool whose instances attach to a new sc2test.Server each.
+type serverPool struct {
+	*LauncherPool
+	mutex   sync.Mutex
+	servers []*sc2test.Server
+}
+
+func newServerPool(t *testing.T, size int, opts ...func(*LauncherPool)) *serverPool {
+	pool := &serverPool{}
+	t.Cleanup(func() {
+		for _, server := range pool.Servers() {
+			server.Close()
+		}
+	})
+	opts = append(opts, PoolClientOpts(func() *Client {
+		server := sc2test.NewServer()
+		pool.mutex.Lock()
+		pool.servers = append(pool.servers, server)
+		pool.mutex.Unlock()
+		return NewClient(ClientAttachOpts(server.Host(), server.Port()))
+	}))
+	var err error
+	pool.LauncherPool, err = NewLauncherPool(context.Background(), size, opts...)
+	if err != nil {
+		t.Fatalf("NewLauncherPool() error: %s", err)
+	}
+	return pool
+}
+
+func (p *serverPool) Servers() []*sc2test.Server {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*sc2test.Server(nil), p.servers...)
+}
+
+func TestLauncherPool_AcquireRelease(t *testing.T) {
+	pool := newServerPool(t, 2, PoolMaxGamesOpts(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	first, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Errorf("Acquire() error: %s", err)
+		return
+	}
+	second, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Errorf("Acquire() error: %s", err)
+		return
+	}
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = pool.Acquire(shortCtx)
+	shortCancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Acquire() to wait for an instance but got: %v", err)
+	}
+
+	// the first game keeps the instance, the second one recycles it
+	pool.Release(first)
+	client, err := pool.Acquire(ctx)
+	if err != nil || client != first {
+		t.Errorf("expected the released instance back but got: %p, %v", client, err)
+		return
+	}
+	pool.Release(first)
+	client, err = pool.Acquire(ctx)
+	if err != nil || client == first {
+		t.Errorf("expected a new instance but got: %p, %v", client, err)
+		return
+	}
+	pool.Release(client)
+	if len(pool.Servers()) != 3 {
+		t.Errorf("unexpected launches: %d", len(pool.Servers()))
+	}
+
+	// instances that died are replaced when acquired
+	for _, server := range pool.Servers() {
+		server.DropConnections()
+	}
+	<-client.Exited()
+	client, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Errorf("Acquire() error: %s", err)
+		return
+	}
+	if _, err := client.rpc.Ping(ctx); err != nil {
+		t.Errorf("unhealthy instance acquired: %s", err)
+	}
+	if len(pool.Servers()) != 4 {
+		t.Errorf("unexpected launches: %d", len(pool.Servers()))
+	}
+	pool.Release(client)
+	pool.Release(second)
+
+	// let the replacements finish launching, Close aborts the launches in progress
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		pool.LauncherPool.mutex.Lock()
+		starting := pool.starting
+		pool.LauncherPool.mutex.Unlock()
+		if starting == 0 {
+			break
+		}
+	}
+	pool.Close()
+	_, err = pool.Acquire(ctx)
+	if !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed but got: %v", err)
+	}
+	// only the instances launched after the connections dropped can quit
+	for _, server := range pool.Servers()[3:] {
+		if server.Status() != sc2proto.Status_quit {
+			t.Errorf("instance not quit, status: %s", server.Status())
+		}
+	}
+}
+
+func TestLauncherPool_ReleaseInGame(t *testing.T) {
+	pool := newServerPool(t, 1)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	first, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Errorf("Acquire() error: %s", err)
+		return
+	}
+	server := pool.Servers()[0]
+
+	// an instance still in its game leaves it when released
+	server.SetStatus(sc2proto.Status_in_game)
+	pool.Release(first)
+	client, err := pool.Acquire(ctx)
+	if err != nil || client != first {
+		t.Errorf("expected the released instance back but got: %p, %v", client, err)
+		return
+	}
+	if server.Status() != sc2proto.Status_launched {
+		t.Errorf("instance not back in launched, status: %s", server.Status())
+	}
+
+	// one that can't leave is replaced
+	server.SetStatus(sc2proto.Status_in_game)
+	server.InjectError("leave_game", "cannot leave")
+	pool.Release(first)
+	client, err = pool.Acquire(ctx)
+	if err != nil || client == first {
+		t.Errorf("expected a new instance but got: %p, %v", client, err)
+		return
+	}
+	pool.Release(client)
+}
@@ -0,0 +1,304 @@
+package sc2client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/internal/status"
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// Record is an entry of a request/response log. On disk each record is a protobuf message
+// prefixed by its varint length, with the fields:
+//
+//	int64    time_unix_nano = 1;
+//	uint32   id             = 2;
+//	Request  request        = 3;
+//	Response response       = 4;
+//
+// so logs can also be decoded by other protobuf tooling.
+type Record struct {
+	Time     time.Time
+	ID       uint32
+	Request  *sc2proto.Request
+	Response *sc2proto.Response
+}
+
+const (
+	recordFieldTime     protowire.Number = 1
+	recordFieldID       protowire.Number = 2
+	recordFieldRequest  protowire.Number = 3
+	recordFieldResponse protowire.Number = 4
+)
+
+func WriteRecord(w io.Writer, record *Record) error {
+	var b []byte
+	b = protowire.AppendTag(b, recordFieldTime, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(record.Time.UnixNano()))
+	b = protowire.AppendTag(b, recordFieldID, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(record.ID))
+	if record.Request != nil {
+		msg, err := proto.Marshal(record.Request)
+		if err != nil {
+			return fmt.Errorf("proto.Marshal() error: %w", err)
+		}
+		b = protowire.AppendTag(b, recordFieldRequest, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	if record.Response != nil {
+		msg, err := proto.Marshal(record.Response)
+		if err != nil {
+			return fmt.Errorf("proto.Marshal() error: %w", err)
+		}
+		b = protowire.AppendTag(b, recordFieldResponse, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	_, err := w.Write(protowire.AppendBytes(nil, b))
+	if err != nil {
+		return fmt.Errorf("w.Write() error: %w", err)
+	}
+	return nil
+}
+
+// ReadRecord reads the next record of a log, returning io.EOF at its end.
+func ReadRecord(r *bufio.Reader) (*Record, error) {
+	size, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, size)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadFull() error: %w", err)
+	}
+	record := &Record{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid record tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		switch {
+		case num == recordFieldTime && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			record.Time = time.Unix(0, int64(v))
+		case num == recordFieldID && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			record.ID = uint32(v)
+		case num == recordFieldRequest && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			record.Request = &sc2proto.Request{}
+			if n >= 0 {
+				err = proto.Unmarshal(v, record.Request)
+			}
+		case num == recordFieldResponse && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			record.Response = &sc2proto.Response{}
+			if n >= 0 {
+				err = proto.Unmarshal(v, record.Response)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid record field %d: %w", num, protowire.ParseError(n))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("proto.Unmarshal() error: %w", err)
+		}
+		b = b[n:]
+	}
+	return record, nil
+}
+
+func readUvarint(r *bufio.Reader) (uint64, error) {
+	var v uint64
+	for shift := 0; shift < 64; shift += 7 {
+		c, err := r.ReadByte()
+		if err != nil {
+			if shift > 0 && errors.Is(err, io.EOF) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("record length overflows")
+}
+
+// RecordingTransport writes every request and response passing through it to a log.
+type RecordingTransport struct {
+	inner Transport
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func NewRecordingTransport(inner Transport, w io.Writer) *RecordingTransport {
+	return &RecordingTransport{
+		inner: inner,
+		w:     w,
+	}
+}
+
+func (t *RecordingTransport) record(record *Record) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	err := WriteRecord(t.w, record)
+	if err != nil {
+		log.Println("[WARN] failed to record message:", err)
+	}
+}
+
+func (t *RecordingTransport) Read(ctx context.Context, rsp *sc2proto.Response) error {
+	err := t.inner.Read(ctx, rsp)
+	if err != nil {
+		return err
+	}
+	t.record(&Record{
+		Time:     time.Now(),
+		ID:       rsp.GetId(),
+		Response: rsp,
+	})
+	return nil
+}
+
+// Write records req only once it was written, a request that failed never reached the
+// game and must not be expected on replay. Its response may be recorded first then, which
+// ReplayTransport doesn't mind.
+func (t *RecordingTransport) Write(ctx context.Context, req *sc2proto.Request) error {
+	now := time.Now()
+	err := t.inner.Write(ctx, req)
+	if err != nil {
+		return err
+	}
+	t.record(&Record{
+		Time:    now,
+		ID:      req.GetId(),
+		Request: req,
+	})
+	return nil
+}
+
+// ReplayTransport serves a log written by RecordingTransport back to an RpcClient. Each
+// request written must be of the same kind as the next recorded one, and is answered with
+// the responses recorded for it, in the recorded order and with their IDs mapped to the
+// IDs of the live requests.
+type ReplayTransport struct {
+	requests  []*Record
+	responses []*Record
+	nextReq   int
+	nextResp  int
+	ids       map[uint32]uint32
+	mutex     sync.Mutex
+	written   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	t := &ReplayTransport{
+		ids:     make(map[uint32]uint32),
+		written: make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	requestIDs := make(map[uint32]bool)
+	br := bufio.NewReader(r)
+	for {
+		record, err := ReadRecord(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ReadRecord() error: %w", err)
+		}
+		if record.Request != nil {
+			t.requests = append(t.requests, record)
+			requestIDs[record.ID] = true
+		}
+		if record.Response != nil {
+			t.responses = append(t.responses, record)
+		}
+	}
+	// responses that no recorded request asked for are replayed as they come
+	for _, record := range t.responses {
+		if !requestIDs[record.ID] {
+			t.ids[record.ID] = record.ID
+		}
+	}
+	return t, nil
+}
+
+func (t *ReplayTransport) Read(ctx context.Context, rsp *sc2proto.Response) error {
+	for {
+		t.mutex.Lock()
+		if t.nextResp >= len(t.responses) {
+			t.mutex.Unlock()
+			return fmt.Errorf("%w: end of replay", ErrConnectionClosed)
+		}
+		record := t.responses[t.nextResp]
+		id, ok := t.ids[record.ID]
+		if ok {
+			t.nextResp++
+			t.mutex.Unlock()
+			proto.Merge(rsp, record.Response)
+			rsp.Id = proto.Uint32(id)
+			return nil
+		}
+		t.mutex.Unlock()
+
+		select {
+		case <-t.written:
+		case <-t.closed:
+			return ErrConnectionClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (t *ReplayTransport) Write(ctx context.Context, req *sc2proto.Request) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	select {
+	case <-t.closed:
+		return ErrConnectionClosed
+	default:
+	}
+	if t.nextReq >= len(t.requests) {
+		return fmt.Errorf("replay exhausted, unexpected request: %s", status.RequestKind(req))
+	}
+	record := t.requests[t.nextReq]
+	if status.RequestKind(record.Request) != status.RequestKind(req) {
+		return fmt.Errorf("replay mismatch at request %d: expected %s but got %s",
+			t.nextReq, status.RequestKind(record.Request), status.RequestKind(req))
+	}
+	t.nextReq++
+	t.ids[record.ID] = req.GetId()
+	select {
+	case t.written <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (t *ReplayTransport) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+}
@@ -0,0 +1,147 @@
+package sc2client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+func TestRecord_WriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Now()
+	err := WriteRecord(&buf, &Record{
+		Time: now,
+		ID:   7,
+		Request: &sc2proto.Request{
+			Id:      proto.Uint32(7),
+			Request: &sc2proto.Request_Ping{Ping: &sc2proto.RequestPing{}},
+		},
+	})
+	if err != nil {
+		t.Errorf("WriteRecord() error: %s", err)
+		return
+	}
+	record, err := ReadRecord(bufio.NewReader(&buf))
+	if err != nil {
+		t.Errorf("ReadRecord() error: %s", err)
+		return
+	}
+	if !record.Time.Equal(now) || record.ID != 7 || record.Request.GetPing() == nil || record.Response != nil {
+		t.Errorf("unexpected record: %+v", record)
+		return
+	}
+}
+
+func TestReplayTransport(t *testing.T) {
+	var gameLoop uint32
+	transport := NewMemoryTransport(func(req *sc2proto.Request) (*sc2proto.Response, error) {
+		resp := &sc2proto.Response{Status: sc2proto.Status_in_game.Enum()}
+		switch r := req.Request.(type) {
+		case *sc2proto.Request_Step:
+			gameLoop += r.Step.GetCount()
+			resp.Response = &sc2proto.Response_Step{Step: &sc2proto.ResponseStep{SimulationLoop: proto.Uint32(gameLoop)}}
+		case *sc2proto.Request_Observation:
+			resp.Response = &sc2proto.Response_Observation{Observation: &sc2proto.ResponseObservation{
+				Observation: &sc2proto.Observation{GameLoop: proto.Uint32(gameLoop)},
+			}}
+		}
+		return resp, nil
+	})
+	defer transport.Close()
+
+	var log bytes.Buffer
+	ctx := context.Background()
+	play := func(rpcCli *RpcClient) []uint32 {
+		var loops []uint32
+		for i := 0; i < 3; i++ {
+			_, obs, err := rpcCli.ActAndObserve(ctx, nil, &sc2proto.RequestStep{Count: proto.Uint32(4)}, &sc2proto.RequestObservation{})
+			if err != nil {
+				t.Errorf("rpcCli.ActAndObserve() error: %s", err)
+				return nil
+			}
+			loops = append(loops, obs.GetObservation().GetGameLoop())
+		}
+		return loops
+	}
+	recorded := play(NewRpcClient(NewRecordingTransport(transport, &log), time.Second))
+
+	replay, err := NewReplayTransport(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Errorf("NewReplayTransport() error: %s", err)
+		return
+	}
+	defer replay.Close()
+	replayed := play(NewRpcClient(replay, time.Second))
+	if len(recorded) != 3 || len(replayed) != 3 {
+		t.Errorf("unexpected game loops: recorded %v, replayed %v", recorded, replayed)
+		return
+	}
+	for i := range recorded {
+		if recorded[i] != replayed[i] {
+			t.Errorf("replay diverged: recorded %v, replayed %v", recorded, replayed)
+			return
+		}
+	}
+
+	replay, err = NewReplayTransport(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Errorf("NewReplayTransport() error: %s", err)
+		return
+	}
+	defer replay.Close()
+	_, err = NewRpcClient(replay, time.Second).Observation(ctx, &sc2proto.RequestObservation{})
+	if err == nil {
+		t.Errorf("expected replay mismatch error")
+	}
+}
+
+func TestRecordingTransport_WriteError(t *testing.T) {
+	transport := NewMemoryTransport(func(req *sc2proto.Request) (*sc2proto.Response, error) {
+		if req.GetQuery() != nil {
+			return nil, errors.New("write failed")
+		}
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_Ping{Ping: &sc2proto.ResponsePing{}},
+			Status:   sc2proto.Status_launched.Enum(),
+		}, nil
+	})
+	defer transport.Close()
+
+	var log bytes.Buffer
+	ctx := context.Background()
+	rpcCli := NewRpcClient(NewRecordingTransport(transport, &log), time.Second)
+	if _, err := rpcCli.Ping(ctx); err != nil {
+		t.Errorf("rpcCli.Ping() error: %s", err)
+		return
+	}
+	if _, err := rpcCli.Query(ctx, &sc2proto.RequestQuery{}); err == nil {
+		t.Errorf("expected write error")
+		return
+	}
+	if _, err := rpcCli.Ping(ctx); err != nil {
+		t.Errorf("rpcCli.Ping() error: %s", err)
+		return
+	}
+
+	// the failed query was never sent, so the replay expects the two pings only
+	replay, err := NewReplayTransport(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Errorf("NewReplayTransport() error: %s", err)
+		return
+	}
+	defer replay.Close()
+	replayCli := NewRpcClient(replay, time.Second)
+	for i := 0; i < 2; i++ {
+		if _, err := replayCli.Ping(ctx); err != nil {
+			t.Errorf("replayCli.Ping() error: %s", err)
+			return
+		}
+	}
+}
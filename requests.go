@@ -0,0 +1,184 @@
+package sc2client
+
+import (
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// The builders below wrap a request payload into the sc2proto.Request envelope. They are
+// shared by the RpcClient methods and the Pipeline, so both always send the same request.
+
+func newPingRequest() *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Ping{
+			Ping: &sc2proto.RequestPing{},
+		},
+	}
+}
+
+func newCreateGameRequest(req *sc2proto.RequestCreateGame) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_CreateGame{
+			CreateGame: req,
+		},
+	}
+}
+
+func newJoinGameRequest(req *sc2proto.RequestJoinGame) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_JoinGame{
+			JoinGame: req,
+		},
+	}
+}
+
+func newRestartGameRequest(req *sc2proto.RequestRestartGame) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_RestartGame{
+			RestartGame: req,
+		},
+	}
+}
+
+func newLeaveGameRequest(req *sc2proto.RequestLeaveGame) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_LeaveGame{
+			LeaveGame: req,
+		},
+	}
+}
+
+func newQuitRequest(req *sc2proto.RequestQuit) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Quit{
+			Quit: req,
+		},
+	}
+}
+
+func newStepRequest(req *sc2proto.RequestStep) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Step{
+			Step: req,
+		},
+	}
+}
+
+func newGameInfoRequest(req *sc2proto.RequestGameInfo) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_GameInfo{
+			GameInfo: req,
+		},
+	}
+}
+
+func newActionRequest(req *sc2proto.RequestAction) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Action{
+			Action: req,
+		},
+	}
+}
+
+func newObservationRequest(req *sc2proto.RequestObservation) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Observation{
+			Observation: req,
+		},
+	}
+}
+
+func newQuickSaveRequest(req *sc2proto.RequestQuickSave) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_QuickSave{
+			QuickSave: req,
+		},
+	}
+}
+
+func newQuickLoadRequest(req *sc2proto.RequestQuickLoad) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_QuickLoad{
+			QuickLoad: req,
+		},
+	}
+}
+
+func newObsActionRequest(req *sc2proto.RequestObserverAction) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_ObsAction{
+			ObsAction: req,
+		},
+	}
+}
+
+func newDataRequest(req *sc2proto.RequestData) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Data{
+			Data: req,
+		},
+	}
+}
+
+func newQueryRequest(req *sc2proto.RequestQuery) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Query{
+			Query: req,
+		},
+	}
+}
+
+func newSaveReplayRequest(req *sc2proto.RequestSaveReplay) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_SaveReplay{
+			SaveReplay: req,
+		},
+	}
+}
+
+func newMapCommandRequest(req *sc2proto.RequestMapCommand) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_MapCommand{
+			MapCommand: req,
+		},
+	}
+}
+
+func newReplayInfoRequest(req *sc2proto.RequestReplayInfo) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_ReplayInfo{
+			ReplayInfo: req,
+		},
+	}
+}
+
+func newAvailableMapsRequest(req *sc2proto.RequestAvailableMaps) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_AvailableMaps{
+			AvailableMaps: req,
+		},
+	}
+}
+
+func newSaveMapRequest(req *sc2proto.RequestSaveMap) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_SaveMap{
+			SaveMap: req,
+		},
+	}
+}
+
+func newStartReplayRequest(req *sc2proto.RequestStartReplay) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_StartReplay{
+			StartReplay: req,
+		},
+	}
+}
+
+func newDebugRequest(req *sc2proto.RequestDebug) *sc2proto.Request {
+	return &sc2proto.Request{
+		Request: &sc2proto.Request_Debug{
+			Debug: req,
+		},
+	}
+}
@@ -2,12 +2,18 @@ package sc2client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/JinWuZhao/sc2client/internal/status"
 	"github.com/JinWuZhao/sc2client/sc2proto"
 )
 
@@ -19,19 +25,46 @@ func (m *IDGenerator) Next() uint32 {
 	return atomic.AddUint32(&m.id, 1)
 }
 
+type pendingCall struct {
+	respChan chan *sc2proto.Response
+	routed   bool
+	err      error
+}
+
 type RpcClient struct {
-	conn     *Connection
-	idgen    IDGenerator
-	respPool map[uint32]chan *sc2proto.Response
-	mutex    sync.RWMutex
-	timeout  time.Duration
+	conn         Transport
+	idgen        IDGenerator
+	respPool     map[uint32]*pendingCall
+	mutex        sync.RWMutex
+	timeout      time.Duration
+	status       int32
+	done         chan struct{}
+	err          error
+	lateCount    uint64
+	orphanCount  uint64
+	unroutedHook func(resp *sc2proto.Response, late bool)
+}
+
+// RpcUnroutedResponseOpts sets a hook called from the message loop for every response
+// that can't be delivered: late is true if its request was already answered, failed or
+// abandoned by a timeout or cancellation, false if its ID doesn't belong to any request
+// sent by this client.
+func RpcUnroutedResponseOpts(hook func(resp *sc2proto.Response, late bool)) func(*RpcClient) {
+	return func(client *RpcClient) {
+		client.unroutedHook = hook
+	}
 }
 
-func NewRpcClient(conn *Connection, timeout time.Duration) *RpcClient {
+func NewRpcClient(conn Transport, timeout time.Duration, opts ...func(*RpcClient)) *RpcClient {
 	rpc := &RpcClient{
 		conn:     conn,
-		respPool: make(map[uint32]chan *sc2proto.Response),
+		respPool: make(map[uint32]*pendingCall),
 		timeout:  timeout,
+		status:   int32(sc2proto.Status_unknown),
+		done:     make(chan struct{}),
+	}
+	for _, option := range opts {
+		option(rpc)
 	}
 	go rpc.runMsgLoop()
 	return rpc
@@ -42,28 +75,127 @@ func (c *RpcClient) runMsgLoop() {
 	for {
 		resp := &sc2proto.Response{}
 		err = c.conn.Read(context.Background(), resp)
+		if errors.Is(err, ErrConnectionReset) {
+			c.failPending(err)
+			continue
+		}
 		if err != nil {
-			err = fmt.Errorf("c.conn.Read() error: %w", err)
+			err = &ConnectionClosedError{Err: fmt.Errorf("c.conn.Read() error: %w", err)}
 			break
 		}
-		c.mutex.RLock()
-		respChan, ok := c.respPool[resp.GetId()]
-		if ok {
-			select {
-			case respChan <- resp:
-			default:
-			}
-		}
-		c.mutex.RUnlock()
+		c.updateStatus(resp)
+		c.routeResponse(resp)
 	}
+	c.err = err
+	close(c.done)
 	log.Println("rpc client message loop stopped. reason:", err)
 }
 
+// routeResponse hands resp over to its pending call. The send never blocks: the channel
+// is buffered and a call is routed at most once, under the same lock its waiter uses to
+// abandon it, so a response racing a timeout is either delivered or reported as late.
+func (c *RpcClient) routeResponse(resp *sc2proto.Response) {
+	reqID := resp.GetId()
+	c.mutex.Lock()
+	call, ok := c.respPool[reqID]
+	if ok && !call.routed {
+		call.routed = true
+		call.respChan <- resp
+		c.mutex.Unlock()
+		return
+	}
+	c.mutex.Unlock()
+
+	// A call still pooled but already routed got its answer or was failed; one no longer
+	// pooled was abandoned. Only an ID this client never issued is orphaned.
+	late := ok || (reqID != 0 && reqID <= atomic.LoadUint32(&c.idgen.id))
+	if late {
+		atomic.AddUint64(&c.lateCount, 1)
+		log.Println("[WARN] rpc client received late response:", reqID)
+	} else {
+		atomic.AddUint64(&c.orphanCount, 1)
+		log.Println("[WARN] rpc client received orphaned response:", reqID)
+	}
+	if c.unroutedHook != nil {
+		c.unroutedHook(resp, late)
+	}
+}
+
+// failPending completes every call still waiting for a response with err, which is
+// used when the connection was re-established and their responses will never arrive.
+func (c *RpcClient) failPending(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, call := range c.respPool {
+		if !call.routed {
+			call.routed = true
+			call.err = err
+			call.respChan <- nil
+		}
+	}
+}
+
+func (c *RpcClient) LateResponses() uint64 {
+	return atomic.LoadUint64(&c.lateCount)
+}
+
+func (c *RpcClient) OrphanedResponses() uint64 {
+	return atomic.LoadUint64(&c.orphanCount)
+}
+
+// Done returns a channel that is closed when the message loop stops, e.g. because the
+// connection to the game was lost. Pending and later requests fail with Err() from then on.
+func (c *RpcClient) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *RpcClient) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+func (c *RpcClient) updateStatus(resp *sc2proto.Response) {
+	if resp.GetQuit() != nil {
+		atomic.StoreInt32(&c.status, int32(sc2proto.Status_quit))
+		return
+	}
+	if resp.Status != nil {
+		atomic.StoreInt32(&c.status, int32(resp.GetStatus()))
+	}
+}
+
+func (c *RpcClient) Status() sc2proto.Status {
+	return sc2proto.Status(atomic.LoadInt32(&c.status))
+}
+
+// SendRequest writes req after checking it is valid in the status the game last reported,
+// and returns the ID to wait for its response with.
 func (c *RpcClient) SendRequest(ctx context.Context, req *sc2proto.Request) (uint32, error) {
+	if err := c.Err(); err != nil {
+		return 0, err
+	}
+	err := checkRequestStatus(status.RequestKind(req), c.Status())
+	if err != nil {
+		return 0, err
+	}
+	return c.writeRequest(ctx, req)
+}
+
+// writeRequest registers and writes req without checking the status, which can't be known
+// in advance for the requests of a pipeline after the first.
+func (c *RpcClient) writeRequest(ctx context.Context, req *sc2proto.Request) (uint32, error) {
+	if err := c.Err(); err != nil {
+		return 0, err
+	}
 	reqID := c.idgen.Next()
-	respChan := make(chan *sc2proto.Response, 1)
 	c.mutex.Lock()
-	c.respPool[reqID] = respChan
+	c.respPool[reqID] = &pendingCall{
+		respChan: make(chan *sc2proto.Response, 1),
+	}
 	c.mutex.Unlock()
 
 	req.Id = &reqID
@@ -71,221 +203,225 @@ func (c *RpcClient) SendRequest(ctx context.Context, req *sc2proto.Request) (uin
 	if err != nil {
 		c.mutex.Lock()
 		delete(c.respPool, reqID)
-		close(respChan)
 		c.mutex.Unlock()
 		return 0, fmt.Errorf("c.conn.Write() error: %w", err)
 	}
 	return reqID, nil
 }
 
-func (c *RpcClient) WaitForResponse(reqID uint32) (*sc2proto.Response, error) {
+func (c *RpcClient) WaitForResponse(ctx context.Context, reqID uint32) (*sc2proto.Response, error) {
 	c.mutex.RLock()
-	respChan, ok := c.respPool[reqID]
+	call, ok := c.respPool[reqID]
 	c.mutex.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("invalid request ID: %d", reqID)
 	}
-	defer func() {
+	var timeout <-chan time.Time
+	if d := c.requestTimeout(ctx); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case resp := <-call.respChan:
 		c.mutex.Lock()
 		delete(c.respPool, reqID)
-		close(respChan)
 		c.mutex.Unlock()
-	}()
-	var resp *sc2proto.Response
-	select {
-	case resp = <-respChan:
+		if resp == nil {
+			return nil, call.err
+		}
 		return resp, nil
-	case <-time.After(c.timeout):
-		return nil, fmt.Errorf("waiting for response timeout: %d", reqID)
+	case <-c.done:
+		err = c.err
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for response %d: %w", reqID, ctx.Err())
+	case <-timeout:
+		err = fmt.Errorf("%w: waiting for response: %d", ErrTimeout, reqID)
 	}
-}
 
-func (c *RpcClient) Ping(ctx context.Context) (*sc2proto.ResponsePing, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_Ping{
-			Ping: &sc2proto.RequestPing{},
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.respPool, reqID)
+	if call.routed {
+		if resp := <-call.respChan; resp != nil {
+			return resp, nil
+		}
+		return nil, call.err
 	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
+	return nil, err
+}
+
+type requestTimeoutKey struct{}
+
+// WithRequestTimeout overrides the default timeout of the RpcClient for the requests made
+// with the returned context. A timeout <= 0 disables it, leaving only the context deadline.
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, timeout)
+}
+
+func (c *RpcClient) requestTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(requestTimeoutKey{}).(time.Duration); ok {
+		return timeout
 	}
-	return resp.GetPing(), nil
+	return c.timeout
 }
 
-func (c *RpcClient) CreateGame(ctx context.Context, req *sc2proto.RequestCreateGame) (*sc2proto.ResponseCreateGame, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_CreateGame{
-			CreateGame: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
+var responseOneof = (&sc2proto.Response{}).ProtoReflect().Descriptor().Oneofs().ByName("response")
+
+// unpackResponse extracts the typed payload answering req from resp.
+func unpackResponse[T proto.Message](req *sc2proto.Request, resp *sc2proto.Response) (T, error) {
+	var result T
+	reqKind := status.RequestKind(req)
+	if len(resp.GetError()) > 0 {
+		return result, &ResponseError{
+			Request: reqKind,
+			Status:  resp.GetStatus(),
+			Errors:  resp.GetError(),
+		}
 	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
+	field := resp.ProtoReflect().WhichOneof(responseOneof)
+	if field == nil {
+		return result, fmt.Errorf("empty response for request: %s", reqKind)
 	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
+	if string(field.Name()) != reqKind {
+		return result, fmt.Errorf("mismatched response for request %s: %s", reqKind, field.Name())
+	}
+	payload := resp.ProtoReflect().Get(field).Message()
+	result, ok := payload.Interface().(T)
+	if !ok {
+		return result, fmt.Errorf("unexpected payload type for response %s: %T", field.Name(), result)
 	}
-	return resp.GetCreateGame(), nil
+	if code := payloadErrorCode(payload); code != nil {
+		respErr := &ResponseError{
+			Request: reqKind,
+			Status:  resp.GetStatus(),
+			Code:    code,
+		}
+		if details := payload.Descriptor().Fields().ByName("error_details"); details != nil {
+			respErr.Details = payload.Get(details).String()
+		}
+		var zero T
+		return zero, respErr
+	}
+	return result, nil
 }
 
-func (c *RpcClient) JoinGame(ctx context.Context, req *sc2proto.RequestJoinGame) (*sc2proto.ResponseJoinGame, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_JoinGame{
-			JoinGame: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
+// payloadErrorCode returns the typed value of the optional "error" enum that several
+// response payloads (create_game, join_game, save_map, ...) carry, or nil if it is unset.
+func payloadErrorCode(payload protoreflect.Message) protoreflect.Enum {
+	field := payload.Descriptor().Fields().ByName("error")
+	if field == nil || field.Kind() != protoreflect.EnumKind || !payload.Has(field) {
+		return nil
+	}
+	number := payload.Get(field).Enum()
+	enumType, err := protoregistry.GlobalTypes.FindEnumByName(field.Enum().FullName())
 	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
+		return nil
 	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetJoinGame(), nil
+	return enumType.New(number)
 }
 
-func (c *RpcClient) RestartGame(ctx context.Context, req *sc2proto.RequestRestartGame) (*sc2proto.ResponseRestartGame, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_RestartGame{
-			RestartGame: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
+func call[T proto.Message](ctx context.Context, c *RpcClient, req *sc2proto.Request) (T, error) {
+	var result T
+	if status.RequestKind(req) == "" {
+		return result, fmt.Errorf("empty request")
 	}
-	resp, err := c.WaitForResponse(id)
+	id, err := c.SendRequest(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
+		return result, fmt.Errorf("c.SendRequest() error: %w", err)
 	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
+	resp, err := c.WaitForResponse(ctx, id)
+	if err != nil {
+		return result, fmt.Errorf("c.WaitForResponse() error: %w", err)
 	}
-	return resp.GetRestartGame(), nil
+	return unpackResponse[T](req, resp)
+}
+
+func (c *RpcClient) Ping(ctx context.Context) (*sc2proto.ResponsePing, error) {
+	return call[*sc2proto.ResponsePing](ctx, c, newPingRequest())
+}
+
+func (c *RpcClient) CreateGame(ctx context.Context, req *sc2proto.RequestCreateGame) (*sc2proto.ResponseCreateGame, error) {
+	return call[*sc2proto.ResponseCreateGame](ctx, c, newCreateGameRequest(req))
+}
+
+func (c *RpcClient) JoinGame(ctx context.Context, req *sc2proto.RequestJoinGame) (*sc2proto.ResponseJoinGame, error) {
+	return call[*sc2proto.ResponseJoinGame](ctx, c, newJoinGameRequest(req))
+}
+
+func (c *RpcClient) RestartGame(ctx context.Context, req *sc2proto.RequestRestartGame) (*sc2proto.ResponseRestartGame, error) {
+	return call[*sc2proto.ResponseRestartGame](ctx, c, newRestartGameRequest(req))
 }
 
 func (c *RpcClient) LeaveGame(ctx context.Context, req *sc2proto.RequestLeaveGame) (*sc2proto.ResponseLeaveGame, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_LeaveGame{
-			LeaveGame: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
-	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetLeaveGame(), nil
+	return call[*sc2proto.ResponseLeaveGame](ctx, c, newLeaveGameRequest(req))
 }
 
 func (c *RpcClient) Quit(ctx context.Context, req *sc2proto.RequestQuit) (*sc2proto.ResponseQuit, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_Quit{
-			Quit: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
-	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetQuit(), nil
+	return call[*sc2proto.ResponseQuit](ctx, c, newQuitRequest(req))
 }
 
 func (c *RpcClient) Step(ctx context.Context, req *sc2proto.RequestStep) (*sc2proto.ResponseStep, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_Step{
-			Step: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
-	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetStep(), nil
+	return call[*sc2proto.ResponseStep](ctx, c, newStepRequest(req))
 }
 
 func (c *RpcClient) GameInfo(ctx context.Context, req *sc2proto.RequestGameInfo) (*sc2proto.ResponseGameInfo, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_GameInfo{
-			GameInfo: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
-	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetGameInfo(), nil
+	return call[*sc2proto.ResponseGameInfo](ctx, c, newGameInfoRequest(req))
 }
 
 func (c *RpcClient) Action(ctx context.Context, req *sc2proto.RequestAction) (*sc2proto.ResponseAction, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_Action{
-			Action: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
-	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetAction(), nil
+	return call[*sc2proto.ResponseAction](ctx, c, newActionRequest(req))
 }
 
 func (c *RpcClient) Observation(ctx context.Context, req *sc2proto.RequestObservation) (*sc2proto.ResponseObservation, error) {
-	id, err := c.SendRequest(ctx, &sc2proto.Request{
-		Request: &sc2proto.Request_Observation{
-			Observation: req,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("c.SendRequest() error: %w", err)
-	}
-	resp, err := c.WaitForResponse(id)
-	if err != nil {
-		return nil, fmt.Errorf("c.WaitForResponse() error: %w", err)
-	}
-	if len(resp.GetError()) > 0 {
-		return nil, fmt.Errorf("sc2 client response error: %+v", resp.GetError())
-	}
-	return resp.GetObservation(), nil
+	return call[*sc2proto.ResponseObservation](ctx, c, newObservationRequest(req))
+}
+
+func (c *RpcClient) QuickSave(ctx context.Context, req *sc2proto.RequestQuickSave) (*sc2proto.ResponseQuickSave, error) {
+	return call[*sc2proto.ResponseQuickSave](ctx, c, newQuickSaveRequest(req))
+}
+
+func (c *RpcClient) QuickLoad(ctx context.Context, req *sc2proto.RequestQuickLoad) (*sc2proto.ResponseQuickLoad, error) {
+	return call[*sc2proto.ResponseQuickLoad](ctx, c, newQuickLoadRequest(req))
+}
+
+func (c *RpcClient) ObsAction(ctx context.Context, req *sc2proto.RequestObserverAction) (*sc2proto.ResponseObserverAction, error) {
+	return call[*sc2proto.ResponseObserverAction](ctx, c, newObsActionRequest(req))
+}
+
+func (c *RpcClient) Data(ctx context.Context, req *sc2proto.RequestData) (*sc2proto.ResponseData, error) {
+	return call[*sc2proto.ResponseData](ctx, c, newDataRequest(req))
+}
+
+func (c *RpcClient) Query(ctx context.Context, req *sc2proto.RequestQuery) (*sc2proto.ResponseQuery, error) {
+	return call[*sc2proto.ResponseQuery](ctx, c, newQueryRequest(req))
+}
+
+func (c *RpcClient) SaveReplay(ctx context.Context, req *sc2proto.RequestSaveReplay) (*sc2proto.ResponseSaveReplay, error) {
+	return call[*sc2proto.ResponseSaveReplay](ctx, c, newSaveReplayRequest(req))
+}
+
+func (c *RpcClient) MapCommand(ctx context.Context, req *sc2proto.RequestMapCommand) (*sc2proto.ResponseMapCommand, error) {
+	return call[*sc2proto.ResponseMapCommand](ctx, c, newMapCommandRequest(req))
+}
+
+func (c *RpcClient) ReplayInfo(ctx context.Context, req *sc2proto.RequestReplayInfo) (*sc2proto.ResponseReplayInfo, error) {
+	return call[*sc2proto.ResponseReplayInfo](ctx, c, newReplayInfoRequest(req))
+}
+
+func (c *RpcClient) AvailableMaps(ctx context.Context, req *sc2proto.RequestAvailableMaps) (*sc2proto.ResponseAvailableMaps, error) {
+	return call[*sc2proto.ResponseAvailableMaps](ctx, c, newAvailableMapsRequest(req))
+}
+
+func (c *RpcClient) SaveMap(ctx context.Context, req *sc2proto.RequestSaveMap) (*sc2proto.ResponseSaveMap, error) {
+	return call[*sc2proto.ResponseSaveMap](ctx, c, newSaveMapRequest(req))
+}
+
+func (c *RpcClient) StartReplay(ctx context.Context, req *sc2proto.RequestStartReplay) (*sc2proto.ResponseStartReplay, error) {
+	return call[*sc2proto.ResponseStartReplay](ctx, c, newStartReplayRequest(req))
+}
+
+func (c *RpcClient) Debug(ctx context.Context, req *sc2proto.RequestDebug) (*sc2proto.ResponseDebug, error) {
+	return call[*sc2proto.ResponseDebug](ctx, c, newDebugRequest(req))
 }
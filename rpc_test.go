@@ -2,10 +2,17 @@ package sc2client
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+
 	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
 )
 
 func TestRpcClient_Ping(t *testing.T) {
@@ -51,3 +58,262 @@ func TestRpcClient_Ping(t *testing.T) {
 		return
 	}
 }
+
+func TestUnpackResponse(t *testing.T) {
+	req := &sc2proto.Request{
+		Request: &sc2proto.Request_Step{
+			Step: &sc2proto.RequestStep{},
+		},
+	}
+	step, err := unpackResponse[*sc2proto.ResponseStep](req, &sc2proto.Response{
+		Response: &sc2proto.Response_Step{
+			Step: &sc2proto.ResponseStep{SimulationLoop: proto.Uint32(16)},
+		},
+	})
+	if err != nil {
+		t.Errorf("unpackResponse() error: %s", err)
+		return
+	}
+	if step.GetSimulationLoop() != 16 {
+		t.Errorf("unexpected simulation loop: %d", step.GetSimulationLoop())
+		return
+	}
+
+	_, err = unpackResponse[*sc2proto.ResponseStep](req, &sc2proto.Response{
+		Response: &sc2proto.Response_Ping{
+			Ping: &sc2proto.ResponsePing{},
+		},
+	})
+	if err == nil {
+		t.Errorf("unpackResponse() expected error for mismatched response")
+		return
+	}
+
+	_, err = unpackResponse[*sc2proto.ResponseStep](req, &sc2proto.Response{})
+	if err == nil {
+		t.Errorf("unpackResponse() expected error for empty response")
+		return
+	}
+}
+
+func dialServer(t *testing.T, server *sc2test.Server) *Connection {
+	conn, err := DialSC2(context.Background(), server.Host(), server.Port())
+	if err != nil {
+		t.Fatalf("DialSC2() error: %s", err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func TestRpcClient_WaitForResponseContext(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	server.SetDelay("ping", time.Second)
+	rpcCli := NewRpcClient(dialServer(t, server), time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := rpcCli.Ping(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded but got: %v", err)
+		return
+	}
+
+	_, err = rpcCli.Ping(WithRequestTimeout(context.Background(), 50*time.Millisecond))
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout but got: %v", err)
+		return
+	}
+
+	rpcCli.mutex.RLock()
+	pending := len(rpcCli.respPool)
+	rpcCli.mutex.RUnlock()
+	if pending != 0 {
+		t.Errorf("unexpected pending requests: %d", pending)
+		return
+	}
+}
+
+func TestRpcClient_MessageLoopStopped(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	server.DropOn("ping")
+	rpcCli := NewRpcClient(dialServer(t, server), time.Minute)
+
+	start := time.Now()
+	_, err := rpcCli.Ping(context.Background())
+	if !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed but got: %v", err)
+		return
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("pending request was not failed immediately")
+		return
+	}
+	select {
+	case <-rpcCli.Done():
+	default:
+		t.Errorf("rpcCli.Done() is not closed")
+		return
+	}
+	if !errors.Is(rpcCli.Err(), ErrConnectionClosed) {
+		t.Errorf("unexpected rpcCli.Err(): %v", rpcCli.Err())
+		return
+	}
+	_, err = rpcCli.SendRequest(context.Background(), &sc2proto.Request{
+		Request: &sc2proto.Request_Ping{
+			Ping: &sc2proto.RequestPing{},
+		},
+	})
+	if !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed but got: %v", err)
+		return
+	}
+}
+
+func TestRpcClient_RoutingStress(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	server.Handle("ping", func(s *sc2test.Server, req *sc2proto.Request) *sc2proto.Response {
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_Ping{
+				Ping: &sc2proto.ResponsePing{
+					GameVersion: proto.String(strconv.Itoa(int(req.GetId()))),
+				},
+			},
+		}
+	})
+	var hookCount uint64
+	rpcCli := NewRpcClient(dialServer(t, server), time.Minute, RpcUnroutedResponseOpts(func(resp *sc2proto.Response, late bool) {
+		if late {
+			atomic.AddUint64(&hookCount, 1)
+		}
+	}))
+
+	const requests = 1000
+	var succeeded, timedOut uint64
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		// every other request gives up before its response is likely to arrive
+		ctx := context.Background()
+		if i%2 == 0 {
+			ctx = WithRequestTimeout(ctx, time.Microsecond)
+		}
+		go func() {
+			defer wg.Done()
+			id, err := rpcCli.SendRequest(ctx, &sc2proto.Request{
+				Request: &sc2proto.Request_Ping{
+					Ping: &sc2proto.RequestPing{},
+				},
+			})
+			if err != nil {
+				t.Errorf("rpcCli.SendRequest() error: %s", err)
+				return
+			}
+			resp, err := rpcCli.WaitForResponse(ctx, id)
+			if errors.Is(err, ErrTimeout) {
+				atomic.AddUint64(&timedOut, 1)
+				return
+			}
+			if err != nil {
+				t.Errorf("rpcCli.WaitForResponse() error: %s", err)
+				return
+			}
+			if resp.GetId() != id || resp.GetPing().GetGameVersion() != strconv.Itoa(int(id)) {
+				t.Errorf("response %d routed to request %d", resp.GetId(), id)
+				return
+			}
+			atomic.AddUint64(&succeeded, 1)
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rpcCli.LateResponses() < timedOut && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if succeeded+timedOut != requests || timedOut == 0 {
+		t.Errorf("lost requests: succeeded %d, timed out %d", succeeded, timedOut)
+	}
+	if rpcCli.LateResponses() != timedOut || atomic.LoadUint64(&hookCount) != timedOut {
+		t.Errorf("late responses: %d, hook calls: %d, timed out: %d", rpcCli.LateResponses(), hookCount, timedOut)
+	}
+	if rpcCli.OrphanedResponses() != 0 {
+		t.Errorf("unexpected orphaned responses: %d", rpcCli.OrphanedResponses())
+	}
+	t.Logf("succeeded: %d, timed out: %d", succeeded, timedOut)
+}
+
+func TestRpcClient_DuplicateResponse(t *testing.T) {
+	conn := NewMemoryTransport(func(req *sc2proto.Request) (*sc2proto.Response, error) {
+		return nil, nil
+	})
+	defer conn.Close()
+	var late, orphaned uint64
+	rpcCli := NewRpcClient(conn, time.Minute, RpcUnroutedResponseOpts(func(resp *sc2proto.Response, isLate bool) {
+		if isLate {
+			atomic.AddUint64(&late, 1)
+		} else {
+			atomic.AddUint64(&orphaned, 1)
+		}
+	}))
+	reqID, err := rpcCli.SendRequest(context.Background(), &sc2proto.Request{
+		Request: &sc2proto.Request_Ping{
+			Ping: &sc2proto.RequestPing{},
+		},
+	})
+	if err != nil {
+		t.Errorf("rpcCli.SendRequest() error: %s", err)
+		return
+	}
+	resp := &sc2proto.Response{
+		Id: proto.Uint32(reqID),
+		Response: &sc2proto.Response_Ping{
+			Ping: &sc2proto.ResponsePing{},
+		},
+	}
+	rpcCli.routeResponse(resp)
+	rpcCli.routeResponse(resp)
+	if _, err = rpcCli.WaitForResponse(context.Background(), reqID); err != nil {
+		t.Errorf("rpcCli.WaitForResponse() error: %s", err)
+		return
+	}
+	if late != 1 || orphaned != 0 || rpcCli.LateResponses() != 1 || rpcCli.OrphanedResponses() != 0 {
+		t.Errorf("late responses: %d, orphaned responses: %d", rpcCli.LateResponses(), rpcCli.OrphanedResponses())
+	}
+}
+
+func TestRpcClient_OrphanedResponse(t *testing.T) {
+	conn := NewMemoryTransport(func(req *sc2proto.Request) (*sc2proto.Response, error) {
+		return &sc2proto.Response{
+			Id: proto.Uint32(req.GetId() + 1000),
+			Response: &sc2proto.Response_Ping{
+				Ping: &sc2proto.ResponsePing{},
+			},
+		}, nil
+	})
+	defer conn.Close()
+	orphaned := make(chan *sc2proto.Response, 1)
+	rpcCli := NewRpcClient(conn, 100*time.Millisecond, RpcUnroutedResponseOpts(func(resp *sc2proto.Response, late bool) {
+		if !late {
+			orphaned <- resp
+		}
+	}))
+	_, err := rpcCli.Ping(context.Background())
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout but got: %v", err)
+		return
+	}
+	select {
+	case resp := <-orphaned:
+		if resp.GetId() != 1001 {
+			t.Errorf("unexpected orphaned response: %d", resp.GetId())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("orphaned response not reported")
+	}
+	if rpcCli.OrphanedResponses() != 1 {
+		t.Errorf("unexpected orphaned responses: %d", rpcCli.OrphanedResponses())
+	}
+}
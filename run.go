@@ -3,14 +3,20 @@ package sc2client
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
 )
 
 type GameMap struct {
 	Name       string
 	SourcePath string
+	// Seed, if set, is the random seed of every game on the map.
+	Seed *uint32
 }
 
 func installGameMap(gameMap GameMap) error {
@@ -40,78 +46,283 @@ func installGameMap(gameMap GameMap) error {
 	return nil
 }
 
-func RunGame(ctx context.Context, gameMaps []GameMap, players []*PlayerSetup, disableFog bool) error {
-	if len(gameMaps) <= 0 {
-		return fmt.Errorf("invalid game map")
+type RunConfig struct {
+	newClient func(index int) *Client
+	restart   *RestartPolicy
+	pool      *LauncherPool
+	stepMode  *StepMode
+	stepCount uint32
+	seed      *uint32
+	replayDir string
+}
+
+// RunClientOpts sets how RunGame creates the Client of the index-th game instance,
+// 0 being the host. There is an instance for every participant and observer, in the
+// order of the players. It is called again whenever that instance is restarted.
+func RunClientOpts(newClient func(index int) *Client) func(*RunConfig) {
+	return func(config *RunConfig) {
+		config.newClient = newClient
+	}
+}
+
+// RunRestartOpts makes RunGame restart a game instance that died and replay the current
+// map, instead of failing the whole run.
+func RunRestartOpts(policy RestartPolicy) func(*RunConfig) {
+	return func(config *RunConfig) {
+		config.restart = &policy
+	}
+}
+
+// RunPoolOpts makes RunGame take its game instances from pool, handing them back after
+// every game so the pool can recycle them. RunClientOpts is ignored then.
+func RunPoolOpts(pool *LauncherPool) func(*RunConfig) {
+	return func(config *RunConfig) {
+		config.pool = pool
+	}
+}
+
+// RunStepModeOpts sets how the games advance on every instance, see ClientStepModeOpts.
+func RunStepModeOpts(mode StepMode, stepCount uint32) func(*RunConfig) {
+	return func(config *RunConfig) {
+		config.stepMode = &mode
+		config.stepCount = stepCount
+	}
+}
+
+// RunSeedOpts seeds the n-th game of the run with seed+n, so a whole run can be
+// reproduced. GameMap.Seed takes precedence.
+func RunSeedOpts(seed uint32) func(*RunConfig) {
+	return func(config *RunConfig) {
+		config.seed = &seed
+	}
+}
+
+// RunReplayDirOpts saves the replay of every game to dir, see ClientReplayDirOpts.
+func RunReplayDirOpts(dir string) func(*RunConfig) {
+	return func(config *RunConfig) {
+		config.replayDir = dir
+	}
+}
+
+func RunGame(ctx context.Context, gameMaps []GameMap, players []*PlayerSetup, disableFog bool, opts ...func(*RunConfig)) error {
+	config := &RunConfig{
+		newClient: func(int) *Client {
+			return NewClient()
+		},
 	}
-	if len(players) != 2 {
-		return fmt.Errorf("need two players")
+	for _, option := range opts {
+		option(config)
 	}
 
-	pc, err := NewPortConfig()
+	if len(gameMaps) <= 0 {
+		return fmt.Errorf("invalid game map")
+	}
+	err := validatePlayerSetups(players)
 	if err != nil {
-		return fmt.Errorf("NewPortConfig() error: %w", err)
+		return fmt.Errorf("validatePlayerSetups() error: %w", err)
+	}
+	// a game instance is started for every participant and observer, the first hosts
+	instances := gameInstancePlayers(players)
+	if len(instances) == 0 {
+		return fmt.Errorf("need a participant or an observer")
 	}
 
-	clients := []*Client{NewClient(), NewClient()}
-	errors := make([]error, 2)
-	var wg sync.WaitGroup
-	wg.Add(len(clients))
-	for i, c := range clients {
+	var policy RestartPolicy
+	if config.restart != nil {
+		policy = *config.restart
+	}
+	supervisors := make([]*Supervisor, len(instances))
+	for i := range supervisors {
 		index := i
-		client := c
-		go func() {
-			err := client.Init(ctx)
-			if err != nil {
-				errors[index] = fmt.Errorf("client.Init() error: %w", err)
+		if config.pool != nil {
+			supervisors[i] = newPoolSupervisor(config.pool, policy)
+			continue
+		}
+		supervisors[i] = NewSupervisor(func() *Client {
+			return config.newClient(index)
+		}, policy)
+	}
+	defer func() {
+		var wg sync.WaitGroup
+		wg.Add(len(supervisors))
+		for _, s := range supervisors {
+			supervisor := s
+			go func() {
+				supervisor.Close()
 				wg.Done()
-				return
+			}()
+		}
+		wg.Wait()
+	}()
+
+	errors := runParallel(len(supervisors), func(index int) error {
+		err := supervisors[index].Start(ctx)
+		if err != nil {
+			return fmt.Errorf("supervisor.Start() error: %w", err)
+		}
+		return nil
+	})
+	if hasError(errors) {
+		return fmt.Errorf("clients error: %s", formatErrors(errors))
+	}
+
+	var mapIndex, games int
+	for {
+		// a single instance plays against computer players without ports
+		var pc *PortConfig
+		if len(instances) > 1 {
+			pc, err = NewPortConfig(len(instances) - 1)
+			if err != nil {
+				return fmt.Errorf("NewPortConfig() error: %w", err)
 			}
-			var mapIndex int
-		gameLoop:
-			for {
-				if index == 0 {
-					err = installGameMap(gameMaps[mapIndex])
-					if err != nil {
-						errors[index] = fmt.Errorf("installGameMap() error: %w", err)
-						break gameLoop
-					}
-					err = client.HostGame(ctx, pc, gameMaps[mapIndex].Name, players, disableFog)
-					if err != nil {
-						errors[index] = fmt.Errorf("client.HostGame() error: %w", err)
-						break gameLoop
-					}
-				} else {
-					err = client.JoinGame(ctx, pc, players)
-					if err != nil {
-						errors[index] = fmt.Errorf("client.JoinGame() error: %w", err)
-						break gameLoop
-					}
-				}
-				client.StartGameLoop(ctx)
-				err = client.WaitGameEnd()
-				if err != nil {
-					errors[index] = fmt.Errorf("client.WaitGameEnd() error: %w", err)
-					break gameLoop
-				}
-				select {
-				case <-ctx.Done():
-					break gameLoop
-				default:
+		}
+		var createGameOpts []func(*sc2proto.RequestCreateGame)
+		if seed := gameMaps[mapIndex].Seed; seed != nil {
+			createGameOpts = append(createGameOpts, CreateGameSeedOpts(*seed))
+		} else if config.seed != nil {
+			createGameOpts = append(createGameOpts, CreateGameSeedOpts(*config.seed+uint32(games)))
+		}
+		errors = runRound(ctx, config, supervisors, pc, gameMaps[mapIndex], players, instances, disableFog, createGameOpts)
+		if ctx.Err() != nil {
+			break
+		}
+		if !hasError(errors) {
+			games++
+			mapIndex++
+			if mapIndex >= len(gameMaps) {
+				mapIndex = 0
+			}
+			if config.pool != nil {
+				errors = runParallel(len(supervisors), func(index int) error {
+					supervisors[index].Close()
+					return supervisors[index].Start(ctx)
+				})
+				if hasError(errors) {
+					break
 				}
-				mapIndex++
-				if mapIndex >= len(gameMaps) {
-					mapIndex = 0
+			}
+			continue
+		}
+		if config.restart == nil || !anyCrashed(supervisors) {
+			break
+		}
+		log.Println("game instance died, replaying map:", gameMaps[mapIndex].Name)
+		for _, supervisor := range supervisors {
+			// the instances still alive may be stuck in the abandoned game
+			if !supervisor.Crashed() {
+				err := supervisor.Client().leaveGame(ctx)
+				if err == nil {
+					continue
 				}
+				log.Println("[WARN] failed to leave abandoned game:", err)
+			}
+			err := supervisor.Restart(ctx)
+			if err != nil {
+				return fmt.Errorf("supervisor.Restart() error: %w, round errors: %s", err, formatErrors(errors))
 			}
-			client.Finalize()
+		}
+	}
+	if ctx.Err() == nil && hasError(errors) {
+		return fmt.Errorf("clients error: %s", formatErrors(errors))
+	}
+	return nil
+}
+
+// runRound plays a game on every instance and waits for all of them. When one fails the
+// others are stopped, so the round can be replayed.
+func runRound(ctx context.Context, config *RunConfig, supervisors []*Supervisor, pc *PortConfig, gameMap GameMap, players []*PlayerSetup, instances []int, disableFog bool, createGameOpts []func(*sc2proto.RequestCreateGame)) []error {
+	roundCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return runParallel(len(supervisors), func(index int) error {
+		client := supervisors[index].Client()
+		if config.stepMode != nil {
+			client.setStepMode(*config.stepMode, config.stepCount)
+		}
+		// the host's replay is enough to re-run the game, the others don't save theirs
+		if index == 0 {
+			if config.replayDir != "" {
+				client.replayDir = config.replayDir
+			}
+		} else {
+			client.replayDir = ""
+		}
+		err := playGame(roundCtx, client, index, instances[index], pc, gameMap, players, disableFog, createGameOpts)
+		if err != nil && roundCtx.Err() == nil {
+			cancel()
+			return err
+		}
+		return nil
+	})
+}
+
+func playGame(ctx context.Context, client *Client, index int, playerIndex int, pc *PortConfig, gameMap GameMap, players []*PlayerSetup, disableFog bool, createGameOpts []func(*sc2proto.RequestCreateGame)) error {
+	// when another instance fails, this one is stopped without tearing down its connection
+	reqCtx, cancel := gracefulContext(ctx)
+	defer cancel()
+	if index == 0 {
+		err := installGameMap(gameMap)
+		if err != nil {
+			return fmt.Errorf("installGameMap() error: %w", err)
+		}
+		err = client.HostGame(reqCtx, pc, gameMap.Name, players, disableFog, createGameOpts...)
+		if err != nil {
+			return fmt.Errorf("client.HostGame() error: %w", err)
+		}
+	} else {
+		err := client.JoinGameAs(reqCtx, pc, players, playerIndex)
+		if err != nil {
+			return fmt.Errorf("client.JoinGameAs() error: %w", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	client.StartGameLoop(ctx)
+	err := client.WaitGameEnd()
+	if err != nil {
+		return fmt.Errorf("client.WaitGameEnd() error: %w", err)
+	}
+	return nil
+}
+
+func runParallel(n int, f func(index int) error) []error {
+	errors := make([]error, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		index := i
+		go func() {
+			errors[index] = f(index)
 			wg.Done()
 		}()
 	}
 	wg.Wait()
+	return errors
+}
 
-	if errors[0] != nil || errors[1] != nil {
-		return fmt.Errorf("clients error: [1](%s), [2](%s)", errors[0], errors[1])
+func hasError(errors []error) bool {
+	for _, err := range errors {
+		if err != nil {
+			return true
+		}
 	}
-	return nil
+	return false
+}
+
+// formatErrors lists the errors of the game instances as [1](err1), [2](err2), ...
+func formatErrors(errors []error) string {
+	parts := make([]string, len(errors))
+	for i, err := range errors {
+		parts[i] = fmt.Sprintf("[%d](%s)", i+1, err)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func anyCrashed(supervisors []*Supervisor) bool {
+	for _, supervisor := range supervisors {
+		if supervisor.Crashed() {
+			return true
+		}
+	}
+	return false
 }
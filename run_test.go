@@ -2,16 +2,22 @@ package sc2client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
 	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
 )
 
 type DirectorAgent struct {
@@ -105,3 +111,317 @@ func TestRun_RunGame(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// attachedRun is a RunGame test attached to fake servers, one per game instance. Its
+// agent plays the first player, and the run is cancelled once games games ended.
+type attachedRun struct {
+	servers []*sc2test.Server
+	agent   *recordAgent
+	ctx     context.Context
+	cancel  context.CancelFunc
+	games   int32
+	ended   int32
+}
+
+// newAttachedRun starts n fake servers created with opts, playing games of 64 loops. They
+// are closed when the test ends.
+func newAttachedRun(t *testing.T, n int, opts ...func(*sc2test.Server)) *attachedRun {
+	r := &attachedRun{
+		games: 1,
+	}
+	for i := 0; i < n; i++ {
+		server := sc2test.NewServer(append([]func(*sc2test.Server){sc2test.ServerGameLengthOpts(64)}, opts...)...)
+		t.Cleanup(server.Close)
+		r.servers = append(r.servers, server)
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	t.Cleanup(r.cancel)
+	r.agent = &recordAgent{onEnd: r.onEnd}
+	return r
+}
+
+// onEnd counts an ended game, to be used by every agent of the run. The host may finish
+// before the others joined, so a run with several agents has to wait for all of them.
+func (r *attachedRun) onEnd() {
+	if atomic.AddInt32(&r.ended, 1) == atomic.LoadInt32(&r.games) {
+		r.cancel()
+	}
+}
+
+func (r *attachedRun) endAfter(games int) {
+	atomic.StoreInt32(&r.games, int32(games))
+}
+
+func (r *attachedRun) newClient(index int) *Client {
+	return NewClient(ClientAttachOpts(r.servers[index].Host(), r.servers[index].Port()))
+}
+
+// players returns the agent playing Terran against a Zerg participant, followed by more.
+func (r *attachedRun) players(more ...*PlayerSetup) []*PlayerSetup {
+	return append([]*PlayerSetup{
+		{
+			Type:  sc2proto.PlayerType_Participant,
+			Race:  sc2proto.Race_Terran,
+			Name:  "Agent",
+			Agent: r.agent,
+		},
+	}, more...)
+}
+
+func opponent() *PlayerSetup {
+	return &PlayerSetup{
+		Type: sc2proto.PlayerType_Participant,
+		Race: sc2proto.Race_Zerg,
+		Name: "Opponent",
+	}
+}
+
+// run plays gameMaps on the servers of the run, opts coming after RunClientOpts.
+func (r *attachedRun) run(gameMaps []GameMap, players []*PlayerSetup, opts ...func(*RunConfig)) error {
+	return RunGame(r.ctx, gameMaps, players, false, append([]func(*RunConfig){RunClientOpts(r.newClient)}, opts...)...)
+}
+
+func (r *attachedRun) checkVictory(t *testing.T) {
+	r.agent.mutex.Lock()
+	defer r.agent.mutex.Unlock()
+	if !r.agent.ended || r.agent.result != sc2proto.Result_Victory {
+		t.Errorf("unexpected agent result: ended %t, result %s", r.agent.ended, r.agent.result)
+	}
+}
+
+var testMap = []GameMap{
+	{Name: "Test.SC2Map"},
+}
+
+func TestRun_RunGameFakeServer(t *testing.T) {
+	r := newAttachedRun(t, 2)
+	err := r.run(testMap, r.players(opponent()))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+	r.checkVictory(t)
+	if r.servers[0].Status() != sc2proto.Status_quit {
+		t.Errorf("host not quit, status: %s", r.servers[0].Status())
+	}
+}
+
+func TestRun_RunGameRestart(t *testing.T) {
+	r := newAttachedRun(t, 2)
+	crashing := sc2test.NewServer(sc2test.ServerGameLengthOpts(64))
+	defer crashing.Close()
+	crashing.DropOn("observation")
+
+	var launches int
+	var restarts []int
+	err := r.run(testMap, r.players(opponent()),
+		RunClientOpts(func(index int) *Client {
+			if index == 0 {
+				launches++
+				if launches == 1 {
+					return NewClient(ClientAttachOpts(crashing.Host(), crashing.Port()))
+				}
+			}
+			return r.newClient(index)
+		}),
+		RunRestartOpts(RestartPolicy{
+			MaxRestarts:    1,
+			InitialBackoff: time.Millisecond,
+			OnRestart: func(n int, err error) {
+				if err != nil {
+					t.Errorf("restart error: %s", err)
+				}
+				restarts = append(restarts, n)
+			},
+		}))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+	if launches != 2 || len(restarts) != 1 {
+		t.Errorf("unexpected restarts: launches %d, restarts %v", launches, restarts)
+	}
+	r.checkVictory(t)
+	if crashing.Status() != sc2proto.Status_in_game || r.servers[0].Status() != sc2proto.Status_quit {
+		t.Errorf("unexpected host status: crashed %s, restarted %s", crashing.Status(), r.servers[0].Status())
+	}
+}
+
+func TestRun_RunGamePool(t *testing.T) {
+	pool := newServerPool(t, 2, PoolMaxGamesOpts(1))
+	defer pool.Close()
+
+	r := newAttachedRun(t, 0)
+	r.endAfter(2)
+	err := r.run(testMap, r.players(opponent()), RunPoolOpts(pool.LauncherPool))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+	if games := atomic.LoadInt32(&r.ended); games != 2 {
+		t.Errorf("unexpected games: %d", games)
+	}
+	// every instance is recycled after its game
+	if len(pool.Servers()) < 4 {
+		t.Errorf("unexpected launches: %d", len(pool.Servers()))
+	}
+}
+
+func TestRun_RunGameStepMode(t *testing.T) {
+	r := newAttachedRun(t, 2)
+	err := r.run(testMap, r.players(opponent()), RunStepModeOpts(StepModeFixed, 16))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+	if r.servers[0].CreateGame().GetRealtime() {
+		t.Errorf("game created in realtime")
+	}
+	r.agent.mutex.Lock()
+	defer r.agent.mutex.Unlock()
+	if !r.agent.ended || len(r.agent.steps) != 4 {
+		t.Errorf("unexpected agent steps: ended %t, steps %v", r.agent.ended, r.agent.steps)
+	}
+	for _, req := range r.servers[1].Requests() {
+		if step := req.GetStep(); step != nil && step.GetCount() != 16 {
+			t.Errorf("unexpected step count: %d", step.GetCount())
+		}
+	}
+}
+
+func TestRun_RunGameSeed(t *testing.T) {
+	r := newAttachedRun(t, 2)
+	r.endAfter(2)
+	replayDir := t.TempDir()
+	err := r.run(
+		[]GameMap{
+			{Name: "Fixed.SC2Map", Seed: proto.Uint32(42)},
+			{Name: "Test.SC2Map"},
+		},
+		r.players(opponent()),
+		RunSeedOpts(100),
+		RunReplayDirOpts(replayDir))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+
+	var seeds []uint32
+	for _, req := range r.servers[0].Requests() {
+		if createGame := req.GetCreateGame(); createGame != nil {
+			seeds = append(seeds, createGame.GetRandomSeed())
+		}
+	}
+	if fmt.Sprint(seeds) != "[42 101]" {
+		t.Errorf("unexpected seeds: %v", seeds)
+	}
+
+	metas, err := filepath.Glob(filepath.Join(replayDir, "*.json"))
+	if err != nil || len(metas) != 2 {
+		t.Errorf("unexpected replay metas: %v, %v", metas, err)
+		return
+	}
+	for _, metaPath := range metas {
+		content, err := os.ReadFile(metaPath)
+		if err != nil {
+			t.Errorf("os.ReadFile() error: %s", err)
+			return
+		}
+		var meta ReplayMeta
+		err = json.Unmarshal(content, &meta)
+		if err != nil {
+			t.Errorf("json.Unmarshal() error: %s", err)
+			return
+		}
+		expectedSeed := map[string]uint32{"Fixed.SC2Map": 42, "Test.SC2Map": 101}[meta.Map]
+		if meta.Seed != expectedSeed || meta.Result != sc2proto.Result_Victory.String() {
+			t.Errorf("unexpected replay meta: %+v", meta)
+		}
+		replayPath := strings.TrimSuffix(metaPath, ".json") + ".SC2Replay"
+		if _, err := os.Stat(replayPath); err != nil {
+			t.Errorf("replay not saved: %s", err)
+		}
+	}
+}
+
+func TestRun_RunGamePlayers(t *testing.T) {
+	r := newAttachedRun(t, 3)
+	r.endAfter(3)
+	err := r.run(testMap, r.players(
+		&PlayerSetup{
+			Type:       sc2proto.PlayerType_Computer,
+			Race:       sc2proto.Race_Zerg,
+			Difficulty: sc2proto.Difficulty_Easy,
+		},
+		&PlayerSetup{
+			Type:  sc2proto.PlayerType_Participant,
+			Race:  sc2proto.Race_Protoss,
+			Name:  "Ally",
+			Agent: &recordAgent{onEnd: r.onEnd},
+		},
+		&PlayerSetup{
+			Type:             sc2proto.PlayerType_Observer,
+			Name:             "Observer",
+			ObservedPlayerId: 1,
+			Interface:        NewInterfaceOptions().Raw(),
+			Agent:            &recordAgent{onEnd: r.onEnd},
+		},
+	))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+	servers := r.servers
+	if n := len(servers[0].CreateGame().GetPlayerSetup()); n != 4 {
+		t.Errorf("unexpected player setups: %d", n)
+	}
+	hostJoin := servers[0].JoinGame()
+	if len(hostJoin.GetClientPorts()) != 2 || hostJoin.GetRace() != sc2proto.Race_Terran {
+		t.Errorf("unexpected host join: %v", hostJoin)
+	}
+	for i, server := range servers[1:] {
+		join := server.JoinGame()
+		if !proto.Equal(join.GetServerPorts(), hostJoin.GetServerPorts()) || len(join.GetClientPorts()) != 2 {
+			t.Errorf("client %d: unexpected ports: %v", i+2, join)
+		}
+		for j, ports := range join.GetClientPorts() {
+			if !proto.Equal(ports, hostJoin.GetClientPorts()[j]) {
+				t.Errorf("client %d: unexpected client ports: %v", i+2, ports)
+			}
+		}
+	}
+	if race := servers[1].JoinGame().GetRace(); race != sc2proto.Race_Protoss {
+		t.Errorf("unexpected ally race: %s", race)
+	}
+	observerJoin := servers[2].JoinGame()
+	if _, ok := observerJoin.GetParticipation().(*sc2proto.RequestJoinGame_ObservedPlayerId); !ok || observerJoin.GetObservedPlayerId() != 1 {
+		t.Errorf("unexpected observer participation: %v", observerJoin.GetParticipation())
+	}
+}
+
+func TestRun_RunGameComputer(t *testing.T) {
+	r := newAttachedRun(t, 1)
+	var launches []int
+	err := r.run(testMap,
+		r.players(&PlayerSetup{
+			Type:       sc2proto.PlayerType_Computer,
+			Race:       sc2proto.Race_Zerg,
+			Difficulty: sc2proto.Difficulty_Hard,
+		}),
+		RunClientOpts(func(index int) *Client {
+			launches = append(launches, index)
+			return r.newClient(index)
+		}))
+	if err != nil {
+		t.Errorf("RunGame() error: %s", err)
+		return
+	}
+	if fmt.Sprint(launches) != "[0]" {
+		t.Errorf("unexpected launches: %v", launches)
+	}
+	r.checkVictory(t)
+	join := r.servers[0].JoinGame()
+	if join.ServerPorts != nil || len(join.GetClientPorts()) != 0 {
+		t.Errorf("unexpected ports: %v", join)
+	}
+}
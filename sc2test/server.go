@@ -0,0 +1,504 @@
+// Package sc2test provides a scriptable fake StarCraft II API server for tests that
+// can't launch the real game.
+package sc2test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"nhooyr.io/websocket"
+
+	"github.com/JinWuZhao/sc2client/internal/status"
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// HandlerFunc answers a request in place of the default behavior of the Server. It is
+// called with the Server locked, so it may use the unexported state through the methods
+// suffixed with Locked. The Server fills in the response ID, and the status if unset.
+type HandlerFunc func(s *Server, req *sc2proto.Request) *sc2proto.Response
+
+type Server struct {
+	httpServer  *httptest.Server
+	mutex       sync.Mutex
+	status      sc2proto.Status
+	gameLoop    uint32
+	gameLength  uint32
+	loopPerObs  uint32
+	realtime    bool
+	remote      bool
+	playerId    uint32
+	result      sc2proto.Result
+	createGame  *sc2proto.RequestCreateGame
+	joinGame    *sc2proto.RequestJoinGame
+	ping        *sc2proto.ResponsePing
+	observation func(gameLoop uint32) *sc2proto.Observation
+	handlers    map[string]HandlerFunc
+	errors      map[string][]string
+	delays      map[string]time.Duration
+	drops       map[string]bool
+	conns       map[*websocket.Conn]struct{}
+	requests    []*sc2proto.Request
+}
+
+// ServerGameLengthOpts sets the game loop at which games end, 22.4 loops being a second
+// of game time on faster speed.
+func ServerGameLengthOpts(gameLoops uint32) func(*Server) {
+	return func(server *Server) {
+		server.gameLength = gameLoops
+	}
+}
+
+// ServerRealtimeStepOpts sets how many game loops pass between two observations of a
+// realtime game.
+func ServerRealtimeStepOpts(gameLoops uint32) func(*Server) {
+	return func(server *Server) {
+		server.loopPerObs = gameLoops
+	}
+}
+
+func ServerResultOpts(result sc2proto.Result) func(*Server) {
+	return func(server *Server) {
+		server.result = result
+	}
+}
+
+func ServerPingOpts(ping *sc2proto.ResponsePing) func(*Server) {
+	return func(server *Server) {
+		server.ping = ping
+	}
+}
+
+// ServerObservationOpts generates the observation returned at each game loop.
+func ServerObservationOpts(observation func(gameLoop uint32) *sc2proto.Observation) func(*Server) {
+	return func(server *Server) {
+		server.observation = observation
+	}
+}
+
+func ServerHandlerOpts(kind string, handler HandlerFunc) func(*Server) {
+	return func(server *Server) {
+		server.handlers[kind] = handler
+	}
+}
+
+func NewServer(opts ...func(*Server)) *Server {
+	server := &Server{
+		status:     sc2proto.Status_launched,
+		gameLength: 224,
+		loopPerObs: 16,
+		result:     sc2proto.Result_Victory,
+		ping: &sc2proto.ResponsePing{
+			GameVersion: proto.String("4.10.0.75689"),
+			DataVersion: proto.String("B89B5D6FA7CBF6452E721311BFBC6CB2"),
+			DataBuild:   proto.Uint32(75689),
+			BaseBuild:   proto.Uint32(75689),
+		},
+		observation: func(gameLoop uint32) *sc2proto.Observation {
+			return &sc2proto.Observation{
+				GameLoop: proto.Uint32(gameLoop),
+			}
+		},
+		handlers: make(map[string]HandlerFunc),
+		errors:   make(map[string][]string),
+		delays:   make(map[string]time.Duration),
+		drops:    make(map[string]bool),
+		conns:    make(map[*websocket.Conn]struct{}),
+	}
+	for _, option := range opts {
+		option(server)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sc2api", server.serveWebsocket)
+	server.httpServer = httptest.NewServer(mux)
+	return server
+}
+
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	return host
+}
+
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return portNum
+}
+
+func (s *Server) Close() {
+	s.DropConnections()
+	s.httpServer.Close()
+}
+
+func (s *Server) Status() sc2proto.Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+func (s *Server) SetStatus(status sc2proto.Status) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = status
+}
+
+func (s *Server) StatusLocked() sc2proto.Status {
+	return s.status
+}
+
+func (s *Server) SetStatusLocked(status sc2proto.Status) {
+	s.status = status
+}
+
+func (s *Server) GameLoop() uint32 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.gameLoop
+}
+
+func (s *Server) GameLoopLocked() uint32 {
+	return s.gameLoop
+}
+
+// CreateGame returns the last create_game request received, e.g. to check its settings.
+func (s *Server) CreateGame() *sc2proto.RequestCreateGame {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.createGame
+}
+
+// JoinGame returns the last join_game request received.
+func (s *Server) JoinGame() *sc2proto.RequestJoinGame {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.joinGame
+}
+
+// Requests returns every request received so far, in order.
+func (s *Server) Requests() []*sc2proto.Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*sc2proto.Request(nil), s.requests...)
+}
+
+func (s *Server) Handle(kind string, handler HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if handler == nil {
+		delete(s.handlers, kind)
+		return
+	}
+	s.handlers[kind] = handler
+}
+
+// InjectError makes the next request of kind fail with errs in Response.error.
+func (s *Server) InjectError(kind string, errs ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errors[kind] = errs
+}
+
+// SetDelay delays every response to requests of kind by d.
+func (s *Server) SetDelay(kind string, d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.delays[kind] = d
+}
+
+// DropOn closes the connection instead of answering the next request of kind.
+func (s *Server) DropOn(kind string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.drops[kind] = true
+}
+
+// DropConnections closes every open connection. The server keeps accepting new ones.
+func (s *Server) DropConnections() {
+	s.mutex.Lock()
+	conns := make([]*websocket.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mutex.Unlock()
+	for _, conn := range conns {
+		_ = conn.Close(websocket.StatusGoingAway, "connection dropped")
+	}
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(1 << 24)
+	s.mutex.Lock()
+	s.conns[conn] = struct{}{}
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+	}()
+
+	ctx := r.Context()
+	for {
+		typ, b, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		if typ != websocket.MessageBinary {
+			_ = conn.Close(websocket.StatusUnsupportedData, "expected binary message")
+			return
+		}
+		req := &sc2proto.Request{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			_ = conn.Close(websocket.StatusInvalidFramePayloadData, "failed to unmarshal protobuf")
+			return
+		}
+		resp, delay, drop := s.handle(req)
+		if drop {
+			_ = conn.Close(websocket.StatusGoingAway, "connection dropped")
+			return
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		b, err = proto.Marshal(resp)
+		if err != nil {
+			return
+		}
+		if err := conn.Write(ctx, websocket.MessageBinary, b); err != nil {
+			return
+		}
+		if resp.GetQuit() != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(req *sc2proto.Request) (*sc2proto.Response, time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req)
+	kind := status.RequestKind(req)
+	if s.drops[kind] {
+		delete(s.drops, kind)
+		return nil, 0, true
+	}
+	var resp *sc2proto.Response
+	if errs, ok := s.errors[kind]; ok {
+		delete(s.errors, kind)
+		resp = &sc2proto.Response{Error: errs}
+	} else if handler, ok := s.handlers[kind]; ok {
+		resp = handler(s, req)
+	} else {
+		resp = s.handleDefault(kind, req)
+		// like the game, exit on quit by closing the connection instead of answering
+		if kind == "quit" && s.status == sc2proto.Status_quit {
+			return nil, 0, true
+		}
+	}
+	if resp == nil {
+		resp = &sc2proto.Response{}
+	}
+	resp.Id = req.Id
+	if resp.Status == nil {
+		resp.Status = s.status.Enum()
+	}
+	return resp, s.delays[kind], false
+}
+
+func (s *Server) handleDefault(kind string, req *sc2proto.Request) *sc2proto.Response {
+	if err := checkStatus(kind, s.status); err != nil {
+		return &sc2proto.Response{Error: []string{err.Error()}}
+	}
+	switch r := req.Request.(type) {
+	case *sc2proto.Request_Ping:
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_Ping{Ping: proto.Clone(s.ping).(*sc2proto.ResponsePing)},
+		}
+	case *sc2proto.Request_CreateGame:
+		return s.handleCreateGame(r.CreateGame)
+	case *sc2proto.Request_JoinGame:
+		return s.handleJoinGame(r.JoinGame)
+	case *sc2proto.Request_RestartGame:
+		s.gameLoop = 0
+		s.status = sc2proto.Status_in_game
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_RestartGame{RestartGame: &sc2proto.ResponseRestartGame{}},
+		}
+	case *sc2proto.Request_LeaveGame:
+		s.status = sc2proto.Status_launched
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_LeaveGame{LeaveGame: &sc2proto.ResponseLeaveGame{}},
+		}
+	case *sc2proto.Request_Quit:
+		// not answered, see handle
+		s.status = sc2proto.Status_quit
+		return nil
+	case *sc2proto.Request_GameInfo:
+		return s.handleGameInfo()
+	case *sc2proto.Request_Observation:
+		return s.handleObservation()
+	case *sc2proto.Request_Step:
+		return s.handleStep(r.Step)
+	case *sc2proto.Request_Action:
+		results := make([]sc2proto.ActionResult, len(r.Action.GetActions()))
+		for i := range results {
+			results[i] = sc2proto.ActionResult_Success
+		}
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_Action{Action: &sc2proto.ResponseAction{Result: results}},
+		}
+	case *sc2proto.Request_ObsAction:
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_ObsAction{ObsAction: &sc2proto.ResponseObserverAction{}},
+		}
+	case *sc2proto.Request_SaveReplay:
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_SaveReplay{SaveReplay: &sc2proto.ResponseSaveReplay{
+				Data: []byte(fmt.Sprintf("sc2test replay: game loop %d", s.gameLoop)),
+			}},
+		}
+	case *sc2proto.Request_QuickSave:
+		return &sc2proto.Response{Response: &sc2proto.Response_QuickSave{QuickSave: &sc2proto.ResponseQuickSave{}}}
+	case *sc2proto.Request_QuickLoad:
+		return &sc2proto.Response{Response: &sc2proto.Response_QuickLoad{QuickLoad: &sc2proto.ResponseQuickLoad{}}}
+	case *sc2proto.Request_Data:
+		return &sc2proto.Response{Response: &sc2proto.Response_Data{Data: &sc2proto.ResponseData{}}}
+	case *sc2proto.Request_Query:
+		return &sc2proto.Response{Response: &sc2proto.Response_Query{Query: &sc2proto.ResponseQuery{}}}
+	case *sc2proto.Request_Debug:
+		return &sc2proto.Response{Response: &sc2proto.Response_Debug{Debug: &sc2proto.ResponseDebug{}}}
+	case *sc2proto.Request_MapCommand:
+		return &sc2proto.Response{Response: &sc2proto.Response_MapCommand{MapCommand: &sc2proto.ResponseMapCommand{}}}
+	case *sc2proto.Request_AvailableMaps:
+		return &sc2proto.Response{Response: &sc2proto.Response_AvailableMaps{AvailableMaps: &sc2proto.ResponseAvailableMaps{}}}
+	case *sc2proto.Request_SaveMap:
+		return &sc2proto.Response{Response: &sc2proto.Response_SaveMap{SaveMap: &sc2proto.ResponseSaveMap{}}}
+	default:
+		return &sc2proto.Response{Error: []string{fmt.Sprintf("sc2test: unsupported request: %s", kind)}}
+	}
+}
+
+func (s *Server) handleCreateGame(req *sc2proto.RequestCreateGame) *sc2proto.Response {
+	createGame := &sc2proto.ResponseCreateGame{}
+	switch {
+	case req.GetMap() == nil:
+		createGame.Error = sc2proto.ResponseCreateGame_MissingMap.Enum()
+	case len(req.GetPlayerSetup()) == 0:
+		createGame.Error = sc2proto.ResponseCreateGame_MissingPlayerSetup.Enum()
+	default:
+		s.createGame = req
+		s.realtime = req.GetRealtime()
+		s.remote = false
+		s.gameLoop = 0
+		s.status = sc2proto.Status_init_game
+	}
+	return &sc2proto.Response{
+		Response: &sc2proto.Response_CreateGame{CreateGame: createGame},
+	}
+}
+
+func (s *Server) handleJoinGame(req *sc2proto.RequestJoinGame) *sc2proto.Response {
+	joinGame := &sc2proto.ResponseJoinGame{}
+	if req.GetParticipation() == nil {
+		joinGame.Error = sc2proto.ResponseJoinGame_MissingParticipation.Enum()
+		return &sc2proto.Response{
+			Response: &sc2proto.Response_JoinGame{JoinGame: joinGame},
+		}
+	}
+	s.playerId = 1
+	if s.status == sc2proto.Status_launched {
+		// a multiplayer client joins the game created by the host on another instance
+		// the game speed is unknown on this side until the client steps
+		s.playerId = 2
+		s.realtime = true
+		s.remote = true
+		s.gameLoop = 0
+	}
+	s.joinGame = req
+	s.status = sc2proto.Status_in_game
+	joinGame.PlayerId = proto.Uint32(s.playerId)
+	return &sc2proto.Response{
+		Response: &sc2proto.Response_JoinGame{JoinGame: joinGame},
+	}
+}
+
+func (s *Server) handleGameInfo() *sc2proto.Response {
+	gameInfo := &sc2proto.ResponseGameInfo{
+		Options: s.joinGame.GetOptions(),
+	}
+	if local := s.createGame.GetLocalMap(); local != nil {
+		gameInfo.MapName = proto.String(local.GetMapPath())
+		gameInfo.LocalMapPath = proto.String(local.GetMapPath())
+	}
+	for i, player := range s.createGame.GetPlayerSetup() {
+		gameInfo.PlayerInfo = append(gameInfo.PlayerInfo, &sc2proto.PlayerInfo{
+			PlayerId:      proto.Uint32(uint32(i + 1)),
+			Type:          player.Type,
+			RaceRequested: player.Race,
+			Difficulty:    player.Difficulty,
+			AiBuild:       player.AiBuild,
+			PlayerName:    player.PlayerName,
+		})
+	}
+	return &sc2proto.Response{
+		Response: &sc2proto.Response_GameInfo{GameInfo: gameInfo},
+	}
+}
+
+func (s *Server) handleObservation() *sc2proto.Response {
+	if s.status == sc2proto.Status_in_game && s.realtime {
+		s.advance(s.loopPerObs)
+	}
+	observation := &sc2proto.ResponseObservation{
+		Observation: s.observation(s.gameLoop),
+	}
+	if s.status == sc2proto.Status_ended {
+		observation.PlayerResult = []*sc2proto.PlayerResult{
+			{
+				PlayerId: proto.Uint32(s.playerId),
+				Result:   s.result.Enum(),
+			},
+		}
+	}
+	return &sc2proto.Response{
+		Response: &sc2proto.Response_Observation{Observation: observation},
+	}
+}
+
+func (s *Server) handleStep(req *sc2proto.RequestStep) *sc2proto.Response {
+	if s.realtime && !s.remote {
+		return &sc2proto.Response{Error: []string{"sc2test: step is not available in realtime mode"}}
+	}
+	s.realtime = false
+	count := req.GetCount()
+	if count == 0 {
+		count = 1
+	}
+	s.advance(count)
+	return &sc2proto.Response{
+		Response: &sc2proto.Response_Step{Step: &sc2proto.ResponseStep{
+			SimulationLoop: proto.Uint32(s.gameLoop),
+		}},
+	}
+}
+
+func (s *Server) advance(gameLoops uint32) {
+	s.gameLoop += gameLoops
+	if s.gameLength > 0 && s.gameLoop >= s.gameLength {
+		s.gameLoop = s.gameLength
+		s.status = sc2proto.Status_ended
+	}
+}
@@ -0,0 +1,143 @@
+package sc2test_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client"
+	"github.com/JinWuZhao/sc2client/sc2proto"
+	"github.com/JinWuZhao/sc2client/sc2test"
+)
+
+func dialServer(t *testing.T, server *sc2test.Server) *sc2client.RpcClient {
+	conn, err := sc2client.DialSC2(context.Background(), server.Host(), server.Port())
+	if err != nil {
+		t.Fatalf("DialSC2() error: %s", err)
+	}
+	t.Cleanup(conn.Close)
+	return sc2client.NewRpcClient(conn, 5*time.Second)
+}
+
+func TestServer_GameFlow(t *testing.T) {
+	server := sc2test.NewServer(sc2test.ServerGameLengthOpts(20))
+	defer server.Close()
+	rpc := dialServer(t, server)
+	ctx := context.Background()
+
+	ping, err := rpc.Ping(ctx)
+	if err != nil {
+		t.Errorf("rpc.Ping() error: %s", err)
+		return
+	}
+	if ping.GetBaseBuild() == 0 || rpc.Status() != sc2proto.Status_launched {
+		t.Errorf("unexpected ping response: %v, status: %s", ping, rpc.Status())
+		return
+	}
+	_, err = rpc.CreateGame(ctx, &sc2proto.RequestCreateGame{
+		Map: &sc2proto.RequestCreateGame_LocalMap{
+			LocalMap: &sc2proto.LocalMap{MapPath: proto.String("Test.SC2Map")},
+		},
+		PlayerSetup: []*sc2proto.PlayerSetup{
+			{Type: sc2proto.PlayerType_Participant.Enum()},
+			{Type: sc2proto.PlayerType_Computer.Enum()},
+		},
+	})
+	if err != nil {
+		t.Errorf("rpc.CreateGame() error: %s", err)
+		return
+	}
+	join, err := rpc.JoinGame(ctx, &sc2proto.RequestJoinGame{
+		Participation: &sc2proto.RequestJoinGame_Race{Race: sc2proto.Race_Terran},
+	})
+	if err != nil {
+		t.Errorf("rpc.JoinGame() error: %s", err)
+		return
+	}
+	if join.GetPlayerId() != 1 || server.Status() != sc2proto.Status_in_game {
+		t.Errorf("unexpected join response: %v, status: %s", join, server.Status())
+		return
+	}
+	step, err := rpc.Step(ctx, &sc2proto.RequestStep{Count: proto.Uint32(8)})
+	if err != nil || step.GetSimulationLoop() != 8 {
+		t.Errorf("unexpected step response: %v, %v", step, err)
+		return
+	}
+	_, err = rpc.Step(ctx, &sc2proto.RequestStep{Count: proto.Uint32(16)})
+	if err != nil {
+		t.Errorf("rpc.Step() error: %s", err)
+		return
+	}
+	obs, err := rpc.Observation(ctx, &sc2proto.RequestObservation{})
+	if err != nil {
+		t.Errorf("rpc.Observation() error: %s", err)
+		return
+	}
+	if len(obs.GetPlayerResult()) != 1 || obs.GetPlayerResult()[0].GetResult() != sc2proto.Result_Victory {
+		t.Errorf("unexpected player result: %v", obs.GetPlayerResult())
+		return
+	}
+	if rpc.Status() != sc2proto.Status_ended {
+		t.Errorf("unexpected status: %s", rpc.Status())
+		return
+	}
+	_, err = rpc.LeaveGame(ctx, &sc2proto.RequestLeaveGame{})
+	if err != nil {
+		t.Errorf("rpc.LeaveGame() error: %s", err)
+		return
+	}
+	// the game closes the connection instead of answering quit
+	_, err = rpc.Quit(ctx, &sc2proto.RequestQuit{})
+	if !errors.Is(err, sc2client.ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed but got: %v", err)
+		return
+	}
+	if server.Status() != sc2proto.Status_quit {
+		t.Errorf("unexpected status: %s", server.Status())
+		return
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	server := sc2test.NewServer()
+	defer server.Close()
+	rpc := dialServer(t, server)
+	ctx := context.Background()
+
+	server.InjectError("ping", "injected error")
+	_, err := rpc.Ping(ctx)
+	var respErr *sc2client.ResponseError
+	if !errors.As(err, &respErr) || respErr.Errors[0] != "injected error" {
+		t.Errorf("expected injected error but got: %v", err)
+		return
+	}
+	_, err = rpc.Ping(ctx)
+	if err != nil {
+		t.Errorf("rpc.Ping() error: %s", err)
+		return
+	}
+
+	server.SetDelay("ping", 200*time.Millisecond)
+	_, err = rpc.Ping(sc2client.WithRequestTimeout(ctx, 50*time.Millisecond))
+	if !errors.Is(err, sc2client.ErrTimeout) {
+		t.Errorf("expected ErrTimeout but got: %v", err)
+		return
+	}
+	server.SetDelay("ping", 0)
+
+	_, err = rpc.CreateGame(ctx, &sc2proto.RequestCreateGame{})
+	if !errors.As(err, &respErr) || respErr.Code != sc2proto.ResponseCreateGame_MissingMap {
+		t.Errorf("expected MissingMap error but got: %v", err)
+		return
+	}
+
+	server.DropOn("ping")
+	_, err = rpc.Ping(ctx)
+	if !errors.Is(err, sc2client.ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed but got: %v", err)
+		return
+	}
+}
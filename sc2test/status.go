@@ -0,0 +1,18 @@
+package sc2test
+
+import (
+	"fmt"
+
+	"github.com/JinWuZhao/sc2client/internal/status"
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+func checkStatus(kind string, s sc2proto.Status) error {
+	if s == sc2proto.Status_quit {
+		return fmt.Errorf("sc2test: game has quit")
+	}
+	if !status.Valid(kind, s) {
+		return fmt.Errorf("sc2test: %s is not valid in status %s", kind, s)
+	}
+	return nil
+}
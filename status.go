@@ -0,0 +1,18 @@
+package sc2client
+
+import (
+	"github.com/JinWuZhao/sc2client/internal/status"
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// checkRequestStatus checks a request against the status the game last reported, which
+// is unknown until the first response.
+func checkRequestStatus(kind string, s sc2proto.Status) error {
+	if s == sc2proto.Status_unknown {
+		return nil
+	}
+	if !status.Valid(kind, s) {
+		return &StatusError{Request: kind, Status: s}
+	}
+	return nil
+}
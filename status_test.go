@@ -0,0 +1,37 @@
+package sc2client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+func TestCheckRequestStatus(t *testing.T) {
+	cases := []struct {
+		kind   string
+		status sc2proto.Status
+		valid  bool
+	}{
+		{"step", sc2proto.Status_launched, false},
+		{"step", sc2proto.Status_in_game, true},
+		{"create_game", sc2proto.Status_in_game, false},
+		{"create_game", sc2proto.Status_launched, true},
+		{"join_game", sc2proto.Status_launched, true},
+		{"ping", sc2proto.Status_in_game, true},
+		{"ping", sc2proto.Status_quit, false},
+		{"observation", sc2proto.Status_unknown, true},
+	}
+	for _, c := range cases {
+		err := checkRequestStatus(c.kind, c.status)
+		if (err == nil) != c.valid {
+			t.Errorf("checkRequestStatus(%s, %s) returned: %v", c.kind, c.status, err)
+		}
+	}
+
+	err := checkRequestStatus("action", sc2proto.Status_ended)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || !errors.Is(err, ErrGameEnded) {
+		t.Errorf("unexpected status error: %v", err)
+	}
+}
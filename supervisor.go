@@ -0,0 +1,140 @@
+package sc2client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RestartPolicy controls how a Supervisor relaunches a game instance that died. Zero values
+// are replaced by defaults. OnRestart, if set, is called once a restart finishes with the
+// number of restarts made so far and nil, or the error that stopped it.
+type RestartPolicy struct {
+	MaxRestarts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	OnRestart      func(restarts int, err error)
+}
+
+// Supervisor keeps a game instance running. It initializes a Client, notices when its game
+// process exits or its connection is lost, and replaces it with a new Client on Restart.
+type Supervisor struct {
+	start    func(ctx context.Context) (*Client, error)
+	stop     func(client *Client)
+	policy   RestartPolicy
+	client   *Client
+	restarts int
+}
+
+func NewSupervisor(newClient func() *Client, policy RestartPolicy) *Supervisor {
+	return newSupervisor(func(ctx context.Context) (*Client, error) {
+		client := newClient()
+		err := client.Init(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("client.Init() error: %w", err)
+		}
+		return client, nil
+	}, (*Client).Finalize, policy)
+}
+
+// newPoolSupervisor takes its Clients from a LauncherPool and hands them back, dead or
+// alive, for the pool to recycle.
+func newPoolSupervisor(pool *LauncherPool, policy RestartPolicy) *Supervisor {
+	return newSupervisor(pool.Acquire, pool.Release, policy)
+}
+
+func newSupervisor(start func(ctx context.Context) (*Client, error), stop func(client *Client), policy RestartPolicy) *Supervisor {
+	if policy.MaxRestarts <= 0 {
+		policy.MaxRestarts = 3
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = time.Second
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 30 * time.Second
+	}
+	return &Supervisor{
+		start:  start,
+		stop:   stop,
+		policy: policy,
+	}
+}
+
+// Start initializes the first Client.
+func (s *Supervisor) Start(ctx context.Context) error {
+	client, err := s.start(ctx)
+	if err != nil {
+		return err
+	}
+	s.client = client
+	return nil
+}
+
+// Client returns the current Client, which changes on every restart.
+func (s *Supervisor) Client() *Client {
+	return s.client
+}
+
+// Restarts returns how many times the game instance has been restarted.
+func (s *Supervisor) Restarts() int {
+	return s.restarts
+}
+
+// Crashed reports whether the game instance of the current Client is gone.
+func (s *Supervisor) Crashed() bool {
+	return s.client != nil && s.client.gone()
+}
+
+// Restart tears the current Client down and initializes a new one, backing off between
+// attempts until one succeeds or the policy runs out of restarts.
+func (s *Supervisor) Restart(ctx context.Context) error {
+	if s.client != nil {
+		if err := s.client.Err(); err != nil {
+			log.Println("[WARN] restarting game instance:", err)
+		}
+		s.stop(s.client)
+		s.client = nil
+	}
+	backoff := s.policy.InitialBackoff
+	var err error
+	for s.restarts < s.policy.MaxRestarts {
+		s.restarts++
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			s.notify(err)
+			return err
+		case <-timer.C:
+		}
+		err = s.Start(ctx)
+		if err == nil {
+			s.notify(nil)
+			return nil
+		}
+		log.Printf("[WARN] restart %d failed: %s\n", s.restarts, err)
+		backoff *= 2
+		if backoff > s.policy.MaxBackoff {
+			backoff = s.policy.MaxBackoff
+		}
+	}
+	err = fmt.Errorf("gave up after %d restarts, last error: %v", s.restarts, err)
+	s.notify(err)
+	return err
+}
+
+func (s *Supervisor) notify(err error) {
+	if s.policy.OnRestart != nil {
+		s.policy.OnRestart(s.restarts, err)
+	}
+}
+
+// Close finalizes the current Client, or hands it back to its pool.
+func (s *Supervisor) Close() {
+	if s.client != nil {
+		s.stop(s.client)
+		s.client = nil
+	}
+}
@@ -0,0 +1,80 @@
+package sc2client
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+// Transport carries requests to the game and responses back to RpcClient. Read is only
+// called by the RpcClient message loop, while Write may be called concurrently. A Read
+// error wrapping ErrConnectionReset fails the pending requests but keeps the loop running,
+// any other Read error stops it.
+type Transport interface {
+	Read(ctx context.Context, rsp *sc2proto.Response) error
+	Write(ctx context.Context, req *sc2proto.Request) error
+}
+
+// MemoryTransport is an in-process Transport answering every request with handle.
+// A nil response sends nothing back, an error fails the Write.
+type MemoryTransport struct {
+	handle    func(req *sc2proto.Request) (*sc2proto.Response, error)
+	resps     chan *sc2proto.Response
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func NewMemoryTransport(handle func(req *sc2proto.Request) (*sc2proto.Response, error)) *MemoryTransport {
+	return &MemoryTransport{
+		handle: handle,
+		resps:  make(chan *sc2proto.Response, 64),
+		closed: make(chan struct{}),
+	}
+}
+
+func (t *MemoryTransport) Read(ctx context.Context, rsp *sc2proto.Response) error {
+	select {
+	case resp := <-t.resps:
+		proto.Merge(rsp, resp)
+		return nil
+	case <-t.closed:
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *MemoryTransport) Write(ctx context.Context, req *sc2proto.Request) error {
+	select {
+	case <-t.closed:
+		return ErrConnectionClosed
+	default:
+	}
+	resp, err := t.handle(proto.Clone(req).(*sc2proto.Request))
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return nil
+	}
+	if resp.Id == nil {
+		resp.Id = proto.Uint32(req.GetId())
+	}
+	select {
+	case t.resps <- resp:
+		return nil
+	case <-t.closed:
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *MemoryTransport) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+}
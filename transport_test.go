@@ -0,0 +1,79 @@
+package sc2client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
+)
+
+func TestMemoryTransport_RpcClientStatus(t *testing.T) {
+	transport := NewMemoryTransport(func(req *sc2proto.Request) (*sc2proto.Response, error) {
+		resp := &sc2proto.Response{}
+		switch req.Request.(type) {
+		case *sc2proto.Request_Ping:
+			resp.Response = &sc2proto.Response_Ping{Ping: &sc2proto.ResponsePing{}}
+			resp.Status = sc2proto.Status_launched.Enum()
+		case *sc2proto.Request_CreateGame:
+			resp.Response = &sc2proto.Response_CreateGame{CreateGame: &sc2proto.ResponseCreateGame{}}
+			resp.Status = sc2proto.Status_init_game.Enum()
+		case *sc2proto.Request_JoinGame:
+			resp.Response = &sc2proto.Response_JoinGame{JoinGame: &sc2proto.ResponseJoinGame{}}
+			resp.Status = sc2proto.Status_in_game.Enum()
+		default:
+			return nil, errors.New("unexpected request")
+		}
+		return resp, nil
+	})
+	defer transport.Close()
+	rpcCli := NewRpcClient(transport, 5*time.Second)
+	ctx := context.Background()
+
+	if rpcCli.Status() != sc2proto.Status_unknown {
+		t.Errorf("unexpected initial status: %s", rpcCli.Status())
+		return
+	}
+	_, err := rpcCli.Ping(ctx)
+	if err != nil {
+		t.Errorf("rpcCli.Ping() error: %s", err)
+		return
+	}
+	if rpcCli.Status() != sc2proto.Status_launched {
+		t.Errorf("unexpected status: %s", rpcCli.Status())
+		return
+	}
+	_, err = rpcCli.Step(ctx, &sc2proto.RequestStep{})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Request != "step" {
+		t.Errorf("expected StatusError but got: %v", err)
+		return
+	}
+	_, err = rpcCli.CreateGame(ctx, &sc2proto.RequestCreateGame{})
+	if err != nil {
+		t.Errorf("rpcCli.CreateGame() error: %s", err)
+		return
+	}
+	_, err = rpcCli.JoinGame(ctx, &sc2proto.RequestJoinGame{})
+	if err != nil {
+		t.Errorf("rpcCli.JoinGame() error: %s", err)
+		return
+	}
+	_, err = rpcCli.CreateGame(ctx, &sc2proto.RequestCreateGame{})
+	if !errors.As(err, &statusErr) || statusErr.Status != sc2proto.Status_in_game {
+		t.Errorf("expected StatusError but got: %v", err)
+		return
+	}
+
+	transport.Close()
+	select {
+	case <-rpcCli.Done():
+	case <-time.After(time.Second):
+		t.Errorf("rpcCli.Done() not closed after transport closed")
+		return
+	}
+	if !errors.Is(rpcCli.Err(), ErrConnectionClosed) {
+		t.Errorf("unexpected rpcCli.Err(): %v", rpcCli.Err())
+	}
+}
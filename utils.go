@@ -6,7 +6,14 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/JinWuZhao/sc2client/sc2proto"
 )
 
 func GetLocalAddress() (string, int, error) {
@@ -23,13 +30,18 @@ func GetLocalAddress() (string, int, error) {
 	return host, portNum, nil
 }
 
+// PortConfig holds the ports of a multiplayer game: the server ports of the host, and a
+// game and base port pair for every client joining it.
 type PortConfig struct {
 	Servers [2]int
-	Players [2]int
+	Players [][2]int
 }
 
-func NewPortConfig() (*PortConfig, error) {
-	pc := new(PortConfig)
+// NewPortConfig allocates the ports of a game joined by guests clients besides the host.
+func NewPortConfig(guests int) (*PortConfig, error) {
+	pc := &PortConfig{
+		Players: make([][2]int, guests),
+	}
 	var err error
 	for i := range pc.Servers {
 		_, pc.Servers[i], err = GetLocalAddress()
@@ -38,15 +50,65 @@ func NewPortConfig() (*PortConfig, error) {
 		}
 	}
 	for i := range pc.Players {
-		_, pc.Players[i], err = GetLocalAddress()
-		if err != nil {
-			return nil, fmt.Errorf("GetLocalAddress() error: %w", err)
+		for j := range pc.Players[i] {
+			_, pc.Players[i][j], err = GetLocalAddress()
+			if err != nil {
+				return nil, fmt.Errorf("GetLocalAddress() error: %w", err)
+			}
 		}
 	}
 	return pc, nil
 }
 
+// portSets returns the ports as sent in RequestJoinGame, the same for every client.
+func (pc *PortConfig) portSets() (*sc2proto.PortSet, []*sc2proto.PortSet) {
+	serverPorts := &sc2proto.PortSet{
+		GamePort: proto.Int32(int32(pc.Servers[0])),
+		BasePort: proto.Int32(int32(pc.Servers[1])),
+	}
+	var clientPorts []*sc2proto.PortSet
+	for _, ports := range pc.Players {
+		clientPorts = append(clientPorts, &sc2proto.PortSet{
+			GamePort: proto.Int32(int32(ports[0])),
+			BasePort: proto.Int32(int32(ports[1])),
+		})
+	}
+	return serverPorts, clientPorts
+}
+
+// sc2PathEnv overrides the discovery of the game installation, as in other SC2 API clients.
+const sc2PathEnv = "SC2PATH"
+
+// sc2ExecutableName returns the path of the game executable within a Versions/Base* directory.
+func sc2ExecutableName() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "SC2_x64.exe"
+	case "darwin":
+		return filepath.Join("SC2.app", "Contents", "MacOS", "SC2")
+	default:
+		return "SC2_x64"
+	}
+}
+
 func GetSC2ClientPath() (string, error) {
+	return GetSC2ClientPathForBuild(0)
+}
+
+// GetSC2ClientPathForBuild returns the executable of the game in Versions/Base<baseBuild>,
+// or of the newest installed build if baseBuild is 0.
+func GetSC2ClientPathForBuild(baseBuild uint32) (string, error) {
+	if os.Getenv(sc2PathEnv) != "" || runtime.GOOS == "linux" || baseBuild != 0 {
+		installDir, err := GetSC2InstallDir()
+		if err != nil {
+			return "", fmt.Errorf("GetSC2InstallDir() error: %w", err)
+		}
+		return findSC2Executable(installDir, baseBuild)
+	}
+	return readExecuteInfo()
+}
+
+func readExecuteInfo() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("os.UserHomeDir() error: %w", err)
@@ -62,10 +124,68 @@ func GetSC2ClientPath() (string, error) {
 	return string(bytes.TrimSuffix(bytes.TrimSpace(stmtParts[1]), []byte{'\n', 0x00})), nil
 }
 
+// GetSC2InstalledBuilds returns the base builds found in the Versions directory of
+// installDir, newest first.
+func GetSC2InstalledBuilds(installDir string) ([]uint32, error) {
+	entries, err := os.ReadDir(filepath.Join(installDir, "Versions"))
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadDir() error: %w", err)
+	}
+	var builds []uint32
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "Base") {
+			continue
+		}
+		build, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), "Base"), 10, 32)
+		if err != nil {
+			continue
+		}
+		builds = append(builds, uint32(build))
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i] > builds[j]
+	})
+	return builds, nil
+}
+
+func findSC2Executable(installDir string, baseBuild uint32) (string, error) {
+	builds, err := GetSC2InstalledBuilds(installDir)
+	if err != nil {
+		return "", fmt.Errorf("GetSC2InstalledBuilds() error: %w", err)
+	}
+	for _, build := range builds {
+		if baseBuild != 0 && build != baseBuild {
+			continue
+		}
+		exePath := filepath.Join(installDir, "Versions", "Base"+strconv.Itoa(int(build)), sc2ExecutableName())
+		if _, err := os.Stat(exePath); err == nil {
+			return exePath, nil
+		}
+	}
+	if baseBuild != 0 {
+		return "", &BuildNotFoundError{
+			BaseBuild:  baseBuild,
+			InstallDir: installDir,
+			Installed:  builds,
+		}
+	}
+	return "", fmt.Errorf("no game executable found in: %s", installDir)
+}
+
 func GetSC2InstallDir() (string, error) {
-	clientPath, err := GetSC2ClientPath()
+	if sc2Path := os.Getenv(sc2PathEnv); sc2Path != "" {
+		return filepath.Abs(sc2Path)
+	}
+	if runtime.GOOS == "linux" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("os.UserHomeDir() error: %w", err)
+		}
+		return filepath.Join(homeDir, "StarCraftII"), nil
+	}
+	clientPath, err := readExecuteInfo()
 	if err != nil {
-		return "", fmt.Errorf("GetSC2ClientPath() error: %w", err)
+		return "", fmt.Errorf("readExecuteInfo() error: %w", err)
 	}
 	clientInstallDir, err := filepath.Abs(filepath.Join(clientPath, "..", "..", ".."))
 	if err != nil {
@@ -0,0 +1,62 @@
+package sc2client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeSC2Install(t *testing.T, builds ...string) string {
+	installDir := t.TempDir()
+	for _, build := range builds {
+		exePath := filepath.Join(installDir, "Versions", "Base"+build, sc2ExecutableName())
+		err := os.MkdirAll(filepath.Dir(exePath), 0755)
+		if err != nil {
+			t.Fatalf("os.MkdirAll() error: %s", err)
+		}
+		err = os.WriteFile(exePath, nil, 0755)
+		if err != nil {
+			t.Fatalf("os.WriteFile() error: %s", err)
+		}
+	}
+	return installDir
+}
+
+func TestGetSC2ClientPath_SC2PATH(t *testing.T) {
+	installDir := makeSC2Install(t, "70000", "75689", "8000")
+	t.Setenv(sc2PathEnv, installDir)
+
+	clientPath, err := GetSC2ClientPath()
+	if err != nil {
+		t.Errorf("GetSC2ClientPath() error: %s", err)
+		return
+	}
+	expected := filepath.Join(installDir, "Versions", "Base75689", sc2ExecutableName())
+	if clientPath != expected {
+		t.Errorf("expected newest build %s but got: %s", expected, clientPath)
+		return
+	}
+
+	clientPath, err = GetSC2ClientPathForBuild(70000)
+	if err != nil {
+		t.Errorf("GetSC2ClientPathForBuild() error: %s", err)
+		return
+	}
+	expected = filepath.Join(installDir, "Versions", "Base70000", sc2ExecutableName())
+	if clientPath != expected {
+		t.Errorf("expected requested build %s but got: %s", expected, clientPath)
+		return
+	}
+
+	_, err = GetSC2ClientPathForBuild(12345)
+	if err == nil {
+		t.Errorf("expected error for missing build")
+		return
+	}
+
+	dir, err := GetSC2InstallDir()
+	if err != nil || dir != installDir {
+		t.Errorf("unexpected install dir: %s, %v", dir, err)
+		return
+	}
+}